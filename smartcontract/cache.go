@@ -0,0 +1,402 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/infrastructure/database"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+)
+
+//DefaultContractCacheSize is the default max entries of a contract cache
+const DefaultContractCacheSize = 10000
+
+//The followings define the kinds of decoded objects in a contract cache
+const (
+	cacheContract byte = iota
+	cacheContractDef
+	cacheAsset
+)
+
+//ContractCacheStats is the metrics of a contract cache
+type ContractCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type cacheEntry struct {
+	object interface{}
+	mci    uint64
+}
+
+type cacheItem struct {
+	key   string
+	entry cacheEntry
+}
+
+//ContractCache is a bounded concurrency-safe cache of decoded contracts, contract definitions and assets
+//it is shared by the CachedContractStore of concurrent validators, the least recently used entry is evicted when it is full
+//every invalidation bumps the version of the cache, a store only adds the objects it loads while the cache has the version
+//the store was wrapped at, so an object read before a write is committed can't be added after the write
+type ContractCache struct {
+	hits    uint64
+	misses  uint64
+	lock    sync.Mutex
+	size    int
+	version uint64
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+//NewContractCache creates a contract cache holding at most size objects
+//a zero size disables the cache
+func NewContractCache(size int) *ContractCache {
+	return &ContractCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func contractCacheKey(kind byte, key ...[]byte) string {
+	buf := []byte{kind}
+	for _, k := range key {
+		buf = append(buf, k...)
+	}
+	return string(buf)
+}
+
+//currentVersion returns the version of the cache, it is bumped by every invalidation
+func (c *ContractCache) currentVersion() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.version
+}
+
+//lookup returns the cached entry of key and counts the hit or miss
+func (c *ContractCache) lookup(key string) (cacheEntry, bool) {
+	if c.size <= 0 {
+		return cacheEntry{}, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return cacheEntry{}, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheItem).entry, true
+}
+
+//add caches the entry of key loaded at version, the entry is dropped if the cache has been invalidated since then
+func (c *ContractCache) add(key string, entry cacheEntry, version uint64) {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if version != c.version {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheItem).entry = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	//evict the least recently used entry when the cache is full
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheItem).key)
+	}
+	c.entries[key] = c.order.PushFront(&cacheItem{key, entry})
+}
+
+//remove drops the entry of key
+func (c *ContractCache) remove(key string) {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.version++
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+//removePrefix drops the entries whose keys start with prefix
+func (c *ContractCache) removePrefix(prefix string) {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.version++
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+//purge drops all entries and keeps the metrics
+func (c *ContractCache) purge() {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.version++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+//Stats returns the metrics of the cache
+func (c *ContractCache) Stats() ContractCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return ContractCacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: len(c.entries),
+	}
+}
+
+//Clear drops all entries and resets the metrics
+func (c *ContractCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.version++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+}
+
+//CachedContractStore is a ContractStore reading decoded objects through a contract cache
+//the objects returned by loads are shared with other readers of the cache and must not be modified
+//writes go to the underlying store and drop the cached objects they change, a store which has written
+//reads the underlying store only, so the objects of uncommitted writes are never cached
+type CachedContractStore struct {
+	ContractStore
+	cache   *ContractCache
+	version uint64
+	written bool
+}
+
+//NewCachedContractStore wraps store with cache, the cache may be shared by wrappers of different stores
+//of the same data, such as the read-only views of concurrent validators
+//the objects loaded by the wrapper are cached until the cache is invalidated by a write, so it is used
+//for stores whose writes are seen at once such as MemoryContractStore, the libraries of database
+//transactions are wrapped by View and Update of ContractCache
+func NewCachedContractStore(store ContractStore, cache *ContractCache) *CachedContractStore {
+	return newCachedContractStore(store, cache, cache.currentVersion())
+}
+
+func newCachedContractStore(store ContractStore, cache *ContractCache, version uint64) *CachedContractStore {
+	return &CachedContractStore{
+		ContractStore: store,
+		cache:         cache,
+		version:       version,
+	}
+}
+
+//View calls fn with the read-only library of a transaction of db wrapped by cache
+//the version of cache is taken before the transaction is opened, so an object read before a write
+//transaction is committed is not cached after the cache is purged by the commit
+func (c *ContractCache) View(db database.Db, fn func(store *CachedContractStore) error) error {
+	version := c.currentVersion()
+	return db.View(func(tx database.Tx) error {
+		return fn(newCachedContractStore(NewContractLibrary(tx, true), c, version))
+	})
+}
+
+//Update calls fn with the writable library of a transaction of db wrapped by cache
+//the cache is purged after the transaction is committed if fn has written
+func (c *ContractCache) Update(db database.Db, fn func(store *CachedContractStore) error) error {
+	var store *CachedContractStore
+	err := db.Update(func(tx database.Tx) error {
+		store = newCachedContractStore(NewContractLibrary(tx, false), c, c.currentVersion())
+		return fn(store)
+	})
+	if err != nil {
+		return err
+	}
+	store.Committed()
+	return nil
+}
+
+//Committed purges the cache if store has written, it is called after the transaction of store is committed
+//a store whose transaction was opened before the commit may have cached the objects as they were before the writes
+func (store *CachedContractStore) Committed() {
+	if store.written {
+		store.cache.purge()
+	}
+}
+
+//invalidate drops the cached object of key for a write of store
+func (store *CachedContractStore) invalidate(key string) {
+	store.written = true
+	store.cache.remove(key)
+}
+
+//Cache returns the contract cache of store
+func (store *CachedContractStore) Cache() *ContractCache {
+	return store.cache
+}
+
+//SaveContract is used to store contract
+func (store *CachedContractStore) SaveContract(contract *structure.Contract, mci uint64) error {
+	defer store.invalidate(contractCacheKey(cacheContract, contract.CalcAddress()))
+	return store.ContractStore.SaveContract(contract, mci)
+}
+
+//LoadContract is to read contract according to the address
+func (store *CachedContractStore) LoadContract(address hash.HashType) (*structure.Contract, uint64, error) {
+	if store.written {
+		return store.ContractStore.LoadContract(address)
+	}
+	key := contractCacheKey(cacheContract, address)
+	if entry, ok := store.cache.lookup(key); ok {
+		return entry.object.(*structure.Contract), entry.mci, nil
+	}
+	contract, mci, err := store.ContractStore.LoadContract(address)
+	if err != nil {
+		return nil, mci, err
+	}
+	store.cache.add(key, cacheEntry{contract, mci}, store.version)
+	return contract, mci, nil
+}
+
+//RemoveContract is to remove contract
+func (store *CachedContractStore) RemoveContract(address hash.HashType) error {
+	defer store.invalidate(contractCacheKey(cacheContract, address))
+	return store.ContractStore.RemoveContract(address)
+}
+
+//SaveAssetContractDef is to store all contracts definition associated with asset
+func (store *CachedContractStore) SaveAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef) error {
+	defer store.invalidate(contractCacheKey(cacheContractDef, asset, contractDef.Address))
+	return store.ContractStore.SaveAssetContractDef(asset, contractDef)
+}
+
+//SaveAssetContractDefAt is to store contract definition associated with asset at mci
+func (store *CachedContractStore) SaveAssetContractDefAt(asset hash.HashType, contractDef *structure.ContractDef, mci uint64) error {
+	defer store.invalidate(contractCacheKey(cacheContractDef, asset, contractDef.Address))
+	return store.ContractStore.SaveAssetContractDefAt(asset, contractDef, mci)
+}
+
+//LoadAssetContractDef is to read contract definition associate with asset
+func (store *CachedContractStore) LoadAssetContractDef(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, error) {
+	if store.written {
+		return store.ContractStore.LoadAssetContractDef(asset, addr)
+	}
+	key := contractCacheKey(cacheContractDef, asset, addr)
+	if entry, ok := store.cache.lookup(key); ok {
+		return entry.object.(*structure.ContractDef), nil
+	}
+	contractDef, err := store.ContractStore.LoadAssetContractDef(asset, addr)
+	if err != nil {
+		return nil, err
+	}
+	store.cache.add(key, cacheEntry{object: contractDef}, store.version)
+	return contractDef, nil
+}
+
+//LoadAssetContract is to read a contract associate with current asset
+func (store *CachedContractStore) LoadAssetContract(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error) {
+	return loadAssetContract(store, asset, addr)
+}
+
+//RemoveAssetContract is to remove a contract associate with asset
+func (store *CachedContractStore) RemoveAssetContract(asset hash.HashType, address hash.HashType) error {
+	defer store.invalidate(contractCacheKey(cacheContractDef, asset, address))
+	return store.ContractStore.RemoveAssetContract(asset, address)
+}
+
+//SaveAsset is to store asset
+func (store *CachedContractStore) SaveAsset(unithash hash.HashType, asset *structure.IssueMessage, mci uint64) error {
+	defer store.invalidate(contractCacheKey(cacheAsset, unithash))
+	return store.ContractStore.SaveAsset(unithash, asset, mci)
+}
+
+//LoadAsset is to read asset
+func (store *CachedContractStore) LoadAsset(assetHash hash.HashType) (*structure.IssueMessage, uint64, error) {
+	if store.written {
+		return store.ContractStore.LoadAsset(assetHash)
+	}
+	key := contractCacheKey(cacheAsset, assetHash)
+	if entry, ok := store.cache.lookup(key); ok {
+		return entry.object.(*structure.IssueMessage), entry.mci, nil
+	}
+	issueMessage, mci, err := store.ContractStore.LoadAsset(assetHash)
+	if err != nil {
+		return nil, mci, err
+	}
+	store.cache.add(key, cacheEntry{issueMessage, mci}, store.version)
+	return issueMessage, mci, nil
+}
+
+//RemoveAsset is to remove asset with the contract definitions associated with it, their cached objects are dropped
+func (store *CachedContractStore) RemoveAsset(asset hash.HashType) error {
+	defer store.cache.removePrefix(contractCacheKey(cacheContractDef, asset))
+	defer store.invalidate(contractCacheKey(cacheAsset, asset))
+	return store.ContractStore.RemoveAsset(asset)
+}
+
+//RollbackTo removes the records stored above mci, the whole cache is cleared
+func (store *CachedContractStore) RollbackTo(mci uint64) error {
+	store.written = true
+	defer store.cache.purge()
+	return store.ContractStore.RollbackTo(mci)
+}
+
+//QuarantineContract moves a corrupted contract to the quarantine
+func (store *CachedContractStore) QuarantineContract(address hash.HashType) error {
+	defer store.invalidate(contractCacheKey(cacheContract, address))
+	return store.ContractStore.QuarantineContract(address)
+}
+
+//QuarantineAsset moves a corrupted asset to the quarantine
+func (store *CachedContractStore) QuarantineAsset(asset hash.HashType) error {
+	defer store.invalidate(contractCacheKey(cacheAsset, asset))
+	return store.ContractStore.QuarantineAsset(asset)
+}
+
+//QuarantineAssetContract moves a corrupted contract definition associated with asset to the quarantine
+func (store *CachedContractStore) QuarantineAssetContract(asset hash.HashType, address hash.HashType) error {
+	defer store.invalidate(contractCacheKey(cacheContractDef, asset, address))
+	return store.ContractStore.QuarantineAssetContract(asset, address)
+}
+
+//CollectGarbage removes the unreferenced contracts stored before beforeMCI and drops them from the cache
+func (store *CachedContractStore) CollectGarbage(beforeMCI uint64) ([]hash.HashType, error) {
+	garbage, err := store.ContractStore.CollectGarbage(beforeMCI)
+	for _, address := range garbage {
+		store.invalidate(contractCacheKey(cacheContract, address))
+	}
+	return garbage, err
+}
@@ -0,0 +1,206 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+)
+
+func TestCachedContractStore(t *testing.T) {
+	cache := NewContractCache(DefaultContractCacheSize)
+	memory := NewMemoryContractStore()
+
+	contract := CreateContract0()
+	address := contract.CalcAddress()
+	asset := FakeRandomHash()
+	msg := FakeIssueMessage()
+	memory.SaveContract(contract, 1)
+	memory.SaveAsset(asset, msg, 1)
+	memory.SaveAssetContractDefAt(asset, CreateRandomContractDef(address), 1)
+
+	store := NewCachedContractStore(memory.ReadOnly(), cache)
+	def0, contract0, err := store.LoadAssetContract(asset, address)
+	if err != nil {
+		t.Fatal("Can't load asset contract, ", err)
+	}
+	def1, contract1, _ := store.LoadAssetContract(asset, address)
+	if def0 != def1 || contract0 != contract1 {
+		t.Error("Decoded objects should be cached.")
+	}
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+
+	//a save drops the cached object, the writer reads its own write without caching it
+	writer := NewCachedContractStore(memory, cache)
+	msg0, _, _ := store.LoadAsset(asset)
+	msg.Name = "renamed asset"
+	writer.SaveAsset(asset, msg, 2)
+	msg1, mci, _ := writer.LoadAsset(asset)
+	if msg0 == msg1 || msg1.Name != "renamed asset" || mci != 2 {
+		t.Error("Saved asset should be loaded again.")
+	}
+	if _, ok := cache.lookup(contractCacheKey(cacheAsset, asset)); ok {
+		t.Error("A store which has written should not cache objects.")
+	}
+
+	//a store wrapped before the write doesn't cache the objects it loads any more
+	store.LoadAsset(asset)
+	if _, ok := cache.lookup(contractCacheKey(cacheAsset, asset)); ok {
+		t.Error("A store wrapped before a write should not cache objects.")
+	}
+
+	//removing an asset drops the cached definitions associated with it
+	NewCachedContractStore(memory.ReadOnly(), cache).LoadAssetContractDef(asset, address)
+	writer.RemoveAsset(asset)
+	if _, err := NewCachedContractStore(memory.ReadOnly(), cache).LoadAssetContractDef(asset, address); err == nil {
+		t.Error("Definition of removed asset should not be loaded from cache.")
+	}
+
+	//a remove drops the cached object
+	writer.RemoveContract(address)
+	if _, _, err := NewCachedContractStore(memory.ReadOnly(), cache).LoadContract(address); err == nil {
+		t.Error("Removed contract should not be loaded from cache.")
+	}
+
+	//a collected contract is dropped from the cache
+	writer.SaveContract(contract, 1)
+	NewCachedContractStore(memory.ReadOnly(), cache).LoadContract(address)
+	writer.EnableGarbageCollection()
+	if removed, err := writer.CollectGarbage(2); err != nil || len(removed) != 1 {
+		t.Fatalf("Can't collect unreferenced contract, %v", err)
+	}
+	if _, _, err := NewCachedContractStore(memory.ReadOnly(), cache).LoadContract(address); err == nil {
+		t.Error("Collected contract should not be loaded from cache.")
+	}
+
+	writer.SaveContract(contract, 1)
+	NewCachedContractStore(memory.ReadOnly(), cache).LoadContract(address)
+	writer.RollbackTo(0)
+	if cache.Stats().Entries != 0 {
+		t.Error("Rollback should drop all cached objects.")
+	}
+
+	NewCachedContractStore(memory.ReadOnly(), cache).LoadContract(address)
+	writer.Committed()
+	if cache.Stats().Entries != 0 {
+		t.Error("Commit of a store which has written should drop all cached objects.")
+	}
+}
+
+func TestContractCache_ViewUpdate(t *testing.T) {
+	db, err := createOrOpenDB("./testContractCache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	CreateSmartContractBucket(db)
+
+	cache := NewContractCache(DefaultContractCacheSize)
+	asset := FakeRandomHash()
+	msg := FakeIssueMessage()
+	err = cache.Update(db, func(store *CachedContractStore) error {
+		return store.SaveAsset(asset, msg, 1)
+	})
+	if err != nil {
+		t.Fatal("Can't save asset, ", err)
+	}
+
+	load := func() *structure.IssueMessage {
+		var issueMessage *structure.IssueMessage
+		cache.View(db, func(store *CachedContractStore) error {
+			issueMessage, _, err = store.LoadAsset(asset)
+			return err
+		})
+		return issueMessage
+	}
+	msg0 := load()
+	if msg0 == nil || msg0 != load() {
+		t.Error("Asset loaded by views should be cached.")
+	}
+
+	//the cache is purged after the write is committed
+	msg.Name = "renamed asset"
+	cache.Update(db, func(store *CachedContractStore) error {
+		return store.SaveAsset(asset, msg, 2)
+	})
+	if msg1 := load(); msg1 == nil || msg1.Name != "renamed asset" {
+		t.Error("Asset saved by a committed write should be loaded again.")
+	}
+}
+
+func TestCachedContractStore_Shared(t *testing.T) {
+	memory := NewMemoryContractStore()
+	asset := FakeRandomHash()
+	memory.SaveAsset(asset, FakeIssueMessage(), 1)
+
+	//the read-only stores of concurrent validators share one cache
+	cache := NewContractCache(DefaultContractCacheSize)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := NewCachedContractStore(memory.ReadOnly(), cache)
+			for j := 0; j < 100; j++ {
+				if _, _, err := store.LoadAsset(asset); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	if stats.Hits+stats.Misses != 800 || stats.Entries != 1 {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+}
+
+func TestContractCache(t *testing.T) {
+	cache := NewContractCache(2)
+	version := cache.currentVersion()
+	cache.add("a", cacheEntry{mci: 1}, version)
+	cache.add("b", cacheEntry{mci: 2}, version)
+	//a is used more recently than b, so b is evicted
+	cache.lookup("a")
+	cache.add("c", cacheEntry{mci: 3}, version)
+	if _, ok := cache.lookup("b"); ok {
+		t.Error("The least recently used entry should be evicted.")
+	}
+	if entry, ok := cache.lookup("a"); !ok || entry.mci != 1 {
+		t.Error("The recently used entry should be kept.")
+	}
+
+	//an object loaded before a write must not be cached after the write invalidates it
+	version = cache.currentVersion()
+	cache.remove("d")
+	cache.add("d", cacheEntry{mci: 4}, version)
+	if _, ok := cache.lookup("d"); ok {
+		t.Error("A stale entry should not be added after an invalidation.")
+	}
+
+	disabled := NewContractCache(0)
+	disabled.add("a", cacheEntry{}, 0)
+	disabled.remove("a")
+	disabled.purge()
+	if stats := disabled.Stats(); stats.Entries != 0 || stats.Misses != 0 {
+		t.Errorf("Unexpected stats of a disabled cache %+v", stats)
+	}
+}
@@ -0,0 +1,245 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"bytes"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+	"github.com/pkg/errors"
+)
+
+//RecordRef locates a stored record by its bucket name and key
+type RecordRef struct {
+	Bucket string
+	Key    hash.HashType
+}
+
+//AssetContractRef is a contract definition associated with asset
+type AssetContractRef struct {
+	Asset    hash.HashType
+	Contract hash.HashType
+}
+
+//ConsistencyReport is the result of CheckConsistency
+type ConsistencyReport struct {
+	//Corrupted are the records failing their checksum, length or decoding check
+	Corrupted []RecordRef
+	//DanglingDefs are the stored contract definitions whose contract is not stored intact and not in the Contracts of their asset
+	DanglingDefs []AssetContractRef
+	//MissingDefs are the contract definitions in the Contracts of an asset which are not stored while their contract is intact
+	MissingDefs []AssetContractRef
+	//MissingContracts are the contracts in the Contracts of an asset which are not stored intact, they can't be repaired
+	MissingContracts []AssetContractRef
+	//UnreferencedContracts are the contracts no contract definition refers to, restricts are among them
+	UnreferencedContracts []hash.HashType
+	//Repaired is the number of problems fixed in repair mode
+	Repaired int
+}
+
+//IsConsistent returns if no problem is found, unreferenced contracts are not problems
+func (report *ConsistencyReport) IsConsistent() bool {
+	return len(report.Corrupted) == 0 && len(report.DanglingDefs) == 0 && len(report.MissingDefs) == 0 &&
+		len(report.MissingContracts) == 0
+}
+
+//CheckConsistency scans the contract, asset and asset contract buckets and reports the problems found
+//in repair mode, corrupted records are quarantined, dangling definitions are removed and missing definitions are
+//restored from the asset, missing contracts and unreferenced contracts are only reported
+//a repaired library is consistent once the missing contracts are stored again
+func (library *ContractLibrary) CheckConsistency(repair bool) (*ConsistencyReport, error) {
+	if repair && library.readOnly {
+		return nil, errors.Errorf(writePermissionsError)
+	}
+	report := new(ConsistencyReport)
+	tx := library.tx
+
+	corrupt := func(bucket []byte, key []byte) {
+		report.Corrupted = append(report.Corrupted, RecordRef{string(bucket), append([]byte(nil), key...)})
+	}
+
+	//contracts are the intact contracts, a corrupted contract is quarantined by repair so it is treated as missing
+	contracts := make([]hash.HashType, 0)
+	intact := make(map[string]bool)
+	err := tx.Data().Bucket(dbnamespace.ContractBucket).ForEach(func(k, v []byte) error {
+		value, err := verifyChecksum(k, v)
+		if err == nil && len(value) < 8 {
+			err = corruptRecordError(k, nil)
+		}
+		if err == nil {
+			err = structure.NewContract().Deserialize(value[8:])
+		}
+		if err != nil {
+			corrupt(dbnamespace.ContractBucket, k)
+			return nil
+		}
+		contracts = append(contracts, append([]byte(nil), k...))
+		intact[string(k)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, NewSmartContractError(ErrForEachDB, "Failed to check contracts", err)
+	}
+
+	assets := make([]hash.HashType, 0)
+	issueMessages := make([]*structure.IssueMessage, 0)
+	err = tx.Data().Bucket(dbnamespace.AssetBucket).ForEach(func(k, v []byte) error {
+		issueMessage := structure.NewIssueMessage()
+		value, err := verifyChecksum(k, v)
+		if err == nil && len(value) < 8 {
+			err = corruptRecordError(k, nil)
+		}
+		if err == nil {
+			err = issueMessage.Deserialize(value[8:])
+		}
+		if err != nil {
+			corrupt(dbnamespace.AssetBucket, k)
+			return nil
+		}
+		assets = append(assets, append([]byte(nil), k...))
+		issueMessages = append(issueMessages, issueMessage)
+		return nil
+	})
+	if err != nil {
+		return nil, NewSmartContractError(ErrForEachDB, "Failed to check assets", err)
+	}
+
+	//listed are the asset contract keys in the Contracts of the assets
+	listed := make(map[string]bool)
+	for i, asset := range assets {
+		for _, contractDef := range issueMessages[i].Contracts {
+			listed[string(asset)+string(contractDef.Address)] = true
+		}
+	}
+
+	defs := make(map[string]bool)
+	referenced := make(map[string]bool)
+	err = tx.Data().Bucket(dbnamespace.AssetContractBucket).ForEach(func(k, v []byte) error {
+		contractDef := new(structure.ContractDef)
+		value, err := verifyChecksum(k, v)
+		if err == nil && len(value) < 8 {
+			err = corruptRecordError(k, nil)
+		}
+		if err == nil {
+			err = contractDef.Deserialize(value[8:])
+		}
+		//the key is the asset hash followed by the contract address of the definition
+		if err != nil || len(k) <= len(contractDef.Address) || !bytes.HasSuffix(k, contractDef.Address) {
+			corrupt(dbnamespace.AssetContractBucket, k)
+			return nil
+		}
+		defs[string(k)] = true
+		referenced[string(contractDef.Address)] = true
+		//a definition listed by its asset is kept, its contract is reported missing with the asset
+		if !intact[string(contractDef.Address)] && !listed[string(k)] {
+			asset := k[:len(k)-len(contractDef.Address)]
+			report.DanglingDefs = append(report.DanglingDefs, AssetContractRef{
+				Asset:    append([]byte(nil), asset...),
+				Contract: append([]byte(nil), contractDef.Address...),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, NewSmartContractError(ErrForEachDB, "Failed to check asset contracts", err)
+	}
+
+	restores := make([]*structure.ContractDef, 0)
+	for i, asset := range assets {
+		for _, contractDef := range issueMessages[i].Contracts {
+			referenced[string(contractDef.Address)] = true
+			if !intact[string(contractDef.Address)] {
+				report.MissingContracts = append(report.MissingContracts, AssetContractRef{asset, contractDef.Address})
+				continue
+			}
+			key := append(append([]byte(nil), asset...), contractDef.Address...)
+			if defs[string(key)] {
+				continue
+			}
+			report.MissingDefs = append(report.MissingDefs, AssetContractRef{asset, contractDef.Address})
+			restores = append(restores, contractDef)
+		}
+	}
+
+	for _, contract := range contracts {
+		if !referenced[string(contract)] {
+			report.UnreferencedContracts = append(report.UnreferencedContracts, contract)
+		}
+	}
+
+	if repair {
+		err = library.repair(report, restores)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+//repair fixes the problems in report, restores are the contract definitions of report.MissingDefs
+func (library *ContractLibrary) repair(report *ConsistencyReport, restores []*structure.ContractDef) error {
+	for _, record := range report.Corrupted {
+		var err error
+		switch record.Bucket {
+		case string(dbnamespace.ContractBucket):
+			err = library.QuarantineContract(record.Key)
+		case string(dbnamespace.AssetBucket):
+			err = library.QuarantineAsset(record.Key)
+		case string(dbnamespace.AssetContractBucket):
+			err = library.quarantineAssetContractKey(record.Key)
+		}
+		if err != nil {
+			return err
+		}
+		report.Repaired++
+	}
+
+	for _, ref := range report.DanglingDefs {
+		err := library.RemoveAssetContract(ref.Asset, ref.Contract)
+		if err != nil {
+			return err
+		}
+		report.Repaired++
+	}
+
+	for i, ref := range report.MissingDefs {
+		//a restored definition is stored at the MCI of its asset
+		err := library.SaveAssetContractDef(ref.Asset, restores[i])
+		if err != nil {
+			return err
+		}
+		report.Repaired++
+	}
+	return nil
+}
+
+//quarantineAssetContractKey quarantines an asset contract record whose key can't be split by its definition
+func (library *ContractLibrary) quarantineAssetContractKey(key hash.HashType) error {
+	record, err := dbQuarantineAssetContract(library.tx, key)
+	if err != nil || record == nil {
+		return err
+	}
+
+	//an asset contract key is the asset hash followed by the contract address of the same size
+	half := len(key) / 2
+	err = dbDeleteContractUsageIndex(library.tx, key[half:], key[:half])
+	if err != nil {
+		return err
+	}
+
+	return dbDeleteMCIIndexOf(library.tx, mciIndexAssetContract, key)
+}
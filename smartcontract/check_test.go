@@ -0,0 +1,164 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/infrastructure/database"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+)
+
+func TestContractLibrary_CheckConsistency(t *testing.T) {
+	db, err := createOrOpenDB("./testCheckConsistency")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		contract0, contract1 := CreateContract0(), CreateContract1()
+		address0, address1 := contract0.CalcAddress(), contract1.CalcAddress()
+		unreferenced := CreateContract1()
+		unreferenced.Name = "unreferenced"
+		library.SaveContract(contract0, 1)
+		library.SaveContract(unreferenced, 1)
+
+		//asset0 refers to contract0 and the removed contract1
+		asset0, asset1, asset2 := FakeRandomHash(), FakeRandomHash(), FakeRandomHash()
+		def0, def1 := CreateRandomContractDef(address0), CreateRandomContractDef(address1)
+		msg0 := FakeIssueMessage()
+		msg0.Contracts = []*structure.ContractDef{def0, def1}
+		library.SaveAsset(asset0, msg0, 1)
+		library.SaveAssetContractDefAt(asset0, def0, 1)
+		library.SaveAssetContractDefAt(asset0, def1, 1)
+
+		//the definition of asset1 is not stored, and it stores a definition of contract1 it doesn't list
+		msg1 := FakeIssueMessage()
+		msg1.Contracts = []*structure.ContractDef{def0}
+		library.SaveAsset(asset1, msg1, 1)
+		library.SaveAssetContractDefAt(asset1, def1, 1)
+
+		//asset2 is corrupted
+		library.SaveAsset(asset2, FakeIssueMessage(), 1)
+		tx.Data().Bucket(dbnamespace.AssetBucket).Put(asset2, []byte("corrupted"))
+
+		report, err := NewContractLibrary(tx, true).CheckConsistency(false)
+		if err != nil {
+			t.Fatal("Can't check consistency, ", err)
+		}
+		if report.IsConsistent() {
+			t.Error("Problems should be found.")
+		}
+		if !reflect.DeepEqual(report.Corrupted, []RecordRef{{string(dbnamespace.AssetBucket), asset2}}) {
+			t.Errorf("Unexpected corrupted records %v", report.Corrupted)
+		}
+		if !reflect.DeepEqual(report.DanglingDefs, []AssetContractRef{{asset1, address1}}) {
+			t.Errorf("Unexpected dangling defs %v", report.DanglingDefs)
+		}
+		if !reflect.DeepEqual(report.MissingDefs, []AssetContractRef{{asset1, address0}}) {
+			t.Errorf("Unexpected missing defs %v", report.MissingDefs)
+		}
+		if !reflect.DeepEqual(report.MissingContracts, []AssetContractRef{{asset0, address1}}) {
+			t.Errorf("Unexpected missing contracts %v", report.MissingContracts)
+		}
+		if !reflect.DeepEqual(report.UnreferencedContracts, []hash.HashType{unreferenced.CalcAddress()}) {
+			t.Errorf("Unexpected unreferenced contracts %v", report.UnreferencedContracts)
+		}
+
+		if _, err := NewContractLibrary(tx, true).CheckConsistency(true); err == nil {
+			t.Error("Read-only library should not repair.")
+		}
+
+		report, err = library.CheckConsistency(true)
+		if err != nil || report.Repaired != 3 {
+			t.Fatalf("Repair error, %d repaired, %v", report.Repaired, err)
+		}
+		if library.HasAssetContract(asset1, address1) || !library.HasAssetContract(asset1, address0) || library.HasAsset(asset2) {
+			t.Error("Problems should be repaired.")
+		}
+		if !library.HasAssetContract(asset0, address1) {
+			t.Error("Definition of a missing contract listed by its asset should be kept.")
+		}
+
+		//asset0 still refers to the removed contract1, which can't be repaired
+		report, _ = library.CheckConsistency(true)
+		if len(report.Corrupted) != 0 || len(report.DanglingDefs) != 0 || len(report.MissingDefs) != 0 ||
+			len(report.UnreferencedContracts) != 1 || report.Repaired != 0 {
+			t.Errorf("Repaired problems should not be found again, %+v", report)
+		}
+		if !reflect.DeepEqual(report.MissingContracts, []AssetContractRef{{asset0, address1}}) {
+			t.Errorf("Unexpected missing contracts after repair %v", report.MissingContracts)
+		}
+
+		//the library is consistent once the missing contract is stored again
+		library.SaveContract(contract1, 1)
+		report, _ = library.CheckConsistency(false)
+		if !report.IsConsistent() {
+			t.Errorf("Library should be consistent, %+v", report)
+		}
+		return nil
+	})
+}
+
+func TestContractLibrary_RepairConsistency(t *testing.T) {
+	db, err := createOrOpenDB("./testRepairConsistency")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		contract0, contract1 := CreateContract0(), CreateContract1()
+		address0, address1 := contract0.CalcAddress(), contract1.CalcAddress()
+		library.SaveContract(contract0, 1)
+		library.SaveContract(contract1, 1)
+
+		//asset0 misses its definition of contract0 and keeps a dangling definition of a removed contract
+		asset0 := FakeRandomHash()
+		msg0 := FakeIssueMessage()
+		msg0.Contracts = []*structure.ContractDef{CreateRandomContractDef(address0)}
+		library.SaveAsset(asset0, msg0, 1)
+		removed := CreateRandomContractDef(FakeRandomHash())
+		library.SaveAssetContractDefAt(asset0, removed, 1)
+
+		//the contract1 record is corrupted, so its definition which asset0 doesn't list is dangling
+		library.SaveAssetContractDefAt(asset0, CreateRandomContractDef(address1), 1)
+		tx.Data().Bucket(dbnamespace.ContractBucket).Put(address1, []byte("corrupted"))
+
+		report, err := library.CheckConsistency(false)
+		if err != nil || report.IsConsistent() {
+			t.Fatalf("Problems should be found, %v", err)
+		}
+		report, err = library.CheckConsistency(true)
+		if err != nil || report.Repaired != 4 {
+			t.Fatalf("Repair error, %+v, %v", report, err)
+		}
+		report, err = library.CheckConsistency(false)
+		if err != nil || !report.IsConsistent() {
+			t.Errorf("Repaired library should be consistent, %+v, %v", report, err)
+		}
+		return nil
+	})
+}
@@ -33,6 +33,7 @@ type ContractLibrary struct {
 const writePermissionsError = "don't have write permissions"
 
 //SaveContract is used to store contract to database
+//contracts are addressed by their content, so a stored contract keeps the MCI it was first stored at
 func (library *ContractLibrary) SaveContract(contract *structure.Contract, mci uint64) error {
 	if library.readOnly {
 		return errors.Errorf(writePermissionsError)
@@ -45,7 +46,16 @@ func (library *ContractLibrary) SaveContract(contract *structure.Contract, mci u
 
 	buf = append(buf, contractBytes...)
 
-	return dbPutContract(library.tx, address, buf)
+	old, _ := dbFetchContract(library.tx, address)
+	if len(old) >= 8 {
+		return nil
+	}
+	err := dbPutContract(library.tx, address, buf)
+	if err != nil {
+		return err
+	}
+
+	return dbPutMCIIndex(library.tx, mci, mciIndexContract, address)
 }
 
 //ListContracts list contracts according to hashes
@@ -53,21 +63,34 @@ func (library *ContractLibrary) ListContracts() ([]hash.HashType, error) {
 	return dbListAllContracts(library.tx)
 }
 
+//ListContractsPaged lists at most limit contract addresses from cursor in address order
+//a nil cursor starts from the first contract, the returned cursor is nil after the last contract
+func (library *ContractLibrary) ListContractsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error) {
+	if limit <= 0 {
+		return nil, nil, errors.Errorf("invalid page limit %d", limit)
+	}
+	contracts, next := dbListContractsPaged(library.tx, cursor, limit)
+	return contracts, next, nil
+}
+
 //LoadContract is to read contract according to the address
 func (library *ContractLibrary) LoadContract(address hash.HashType) (*structure.Contract, uint64, error) {
-	var buf []byte
 	data, err := dbFetchContract(library.tx, address)
-	buf = data
-
 	if err != nil {
 		return nil, math.MaxUint64, err
 	}
+	if len(data) < 8 {
+		return nil, math.MaxUint64, corruptRecordError(address, nil)
+	}
 	mci := binary.BigEndian.Uint64(data)
 
 	contract := structure.NewContract()
-	contract.Deserialize(buf[8:])
+	err = contract.Deserialize(data[8:])
+	if err != nil {
+		return nil, math.MaxUint64, corruptRecordError(address, err)
+	}
 
-	return contract, mci, err
+	return contract, mci, nil
 }
 
 //HasContract returns if the contract is existed
@@ -81,73 +104,145 @@ func (library *ContractLibrary) RemoveContract(address hash.HashType) error {
 		return errors.Errorf(writePermissionsError)
 	}
 
-	return dbDeleteContract(library.tx, address)
+	old, _ := dbFetchContract(library.tx, address)
+	err := dbDeleteContract(library.tx, address)
+	if err != nil || len(old) < 8 {
+		return err
+	}
 
+	return dbDeleteMCIIndex(library.tx, mciIndexKey(binary.BigEndian.Uint64(old), mciIndexContract, address))
 }
 
 //SaveAssetContractDef is to store all contracts definition associated with asset
+//the definition is stored at the MCI of the asset, or 0 if the asset is not stored
 func (library *ContractLibrary) SaveAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef) error {
+	return library.SaveAssetContractDefAt(asset, contractDef, dbFetchAssetMCI(library.tx, asset))
+}
+
+//SaveAssetContractDefAt is to store contract definition associated with asset at mci
+//the definition is indexed by the last MCI it is stored at
+func (library *ContractLibrary) SaveAssetContractDefAt(asset hash.HashType, contractDef *structure.ContractDef, mci uint64) error {
 	if library.readOnly {
 		return errors.Errorf(writePermissionsError)
 	}
 
+	return library.putAssetContractDef(asset, contractDef, mci, true)
+}
+
+//putAssetContractDef stores contract definition associated with asset at mci
+//the value it overwrites is kept in an undo record if undo is set
+func (library *ContractLibrary) putAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef, mci uint64, undo bool) error {
 	addr := contractDef.Address
-	key := append(asset, addr...)
-	value := contractDef.Serialize()
+	key := append(append([]byte(nil), asset...), addr...)
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+	buf = append(buf, contractDef.Serialize()...)
+
+	existed := dbHasAssetContract(library.tx, key)
+	old, _ := dbFetchAssetContract(library.tx, key)
+	if undo {
+		err := library.saveUndo(mciIndexAssetContract, key, mci, old)
+		if err != nil {
+			return err
+		}
+	}
+	err := dbPutAssetContract(library.tx, key, buf)
+	if err != nil {
+		return err
+	}
 
-	return dbPutAssetContract(library.tx, key, value)
+	err = dbReindexMCI(library.tx, mci, mciIndexAssetContract, key, old)
+	if err != nil {
+		return err
+	}
+	err = dbPutContractUsageIndex(library.tx, addr, asset)
+	if err != nil || existed {
+		return err
+	}
+
+	return dbAddContractRef(library.tx, addr, 1)
 }
 
 //LoadAssetContractDef is to read contract definition associate with asset
 func (library *ContractLibrary) LoadAssetContractDef(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, error) {
-	key := append(asset, addr...)
-	var buf []byte
-
+	key := append(append([]byte(nil), asset...), addr...)
 	data, err := dbFetchAssetContract(library.tx, key)
-	buf = data
-
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 8 {
+		return nil, corruptRecordError(key, nil)
+	}
 
 	contractDef := new(structure.ContractDef)
-	contractDef.Deserialize(buf)
+	err = contractDef.Deserialize(data[8:])
+	if err != nil {
+		return nil, corruptRecordError(key, err)
+	}
 
 	return contractDef, nil
 }
 
+//ListAssetContractDefs lists contract definitions associated with asset
+func (library *ContractLibrary) ListAssetContractDefs(asset hash.HashType) ([]*structure.ContractDef, error) {
+	values, err := dbListAssetContracts(library.tx, asset)
+	if err != nil {
+		return nil, err
+	}
+	contractDefs := make([]*structure.ContractDef, 0, len(values))
+	for _, value := range values {
+		if len(value) < 8 {
+			return nil, corruptRecordError(asset, nil)
+		}
+		contractDef := new(structure.ContractDef)
+		err := contractDef.Deserialize(value[8:])
+		if err != nil {
+			return nil, corruptRecordError(asset, err)
+		}
+		contractDefs = append(contractDefs, contractDef)
+	}
+	return contractDefs, nil
+}
+
 //RemoveAssetContract is to remove a contract associate with asset
 func (library *ContractLibrary) RemoveAssetContract(asset hash.HashType, address hash.HashType) error {
 	if library.readOnly {
 		return errors.Errorf(writePermissionsError)
 	}
 
-	key := append(asset, address...)
+	key := append(append([]byte(nil), asset...), address...)
 
+	existed := dbHasAssetContract(library.tx, key)
+	old, _ := dbFetchAssetContract(library.tx, key)
 	err := dbDeleteAssetContract(library.tx, key)
-
-	return err
-}
-
-//LoadAssetContract is to read a contract associate with current asset
-func (library *ContractLibrary) LoadAssetContract(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error) {
-	contractDef, err := library.LoadAssetContractDef(asset, addr)
-
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-
-	if !library.HasContract(contractDef.Address) {
-		return contractDef, nil, errors.Errorf("Can't find contract from Contract library.")
+	err = dbDeleteUndoOf(library.tx, mciIndexAssetContract, key)
+	if err != nil {
+		return err
 	}
 
-	contract, _, err := library.LoadContract(contractDef.Address)
-
+	err = dbDeleteContractUsageIndex(library.tx, address, asset)
+	if err != nil || !existed {
+		return err
+	}
+	if len(old) >= 8 {
+		err = dbDeleteMCIIndex(library.tx, mciIndexKey(binary.BigEndian.Uint64(old), mciIndexAssetContract, key))
+	} else {
+		err = dbDeleteMCIIndexOf(library.tx, mciIndexAssetContract, key)
+	}
 	if err != nil {
-		return contractDef, nil, err
+		return err
 	}
 
-	return contractDef, contract, err
+	return dbAddContractRef(library.tx, address, -1)
+}
+
+//LoadAssetContract is to read a contract associate with current asset
+func (library *ContractLibrary) LoadAssetContract(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error) {
+	return loadAssetContract(library, asset, addr)
 }
 
 //SaveAsset is to store asset
@@ -155,6 +250,12 @@ func (library *ContractLibrary) SaveAsset(unithash hash.HashType, asset *structu
 	if library.readOnly {
 		return errors.Errorf(writePermissionsError)
 	}
+
+	return library.putAsset(unithash, asset, mci, true)
+}
+
+//putAsset stores asset at mci, the value it overwrites is kept in an undo record if undo is set
+func (library *ContractLibrary) putAsset(unithash hash.HashType, asset *structure.IssueMessage, mci uint64, undo bool) error {
 	address := unithash
 	//address := asset.CalcPayloadHash()
 
@@ -164,37 +265,135 @@ func (library *ContractLibrary) SaveAsset(unithash hash.HashType, asset *structu
 	assetBytes := asset.Serialize()
 	buf = append(buf, assetBytes...)
 
-	return dbPutAsset(library.tx, address, buf)
+	//the indexes of the stored asset are removed before it is overwritten
+	var old []byte
+	if dbHasAsset(library.tx, address) {
+		old, _ = dbFetchAsset(library.tx, address)
+		err := library.unindexAsset(address, old)
+		if err != nil {
+			return err
+		}
+	}
+	if undo {
+		err := library.saveUndo(mciIndexAsset, address, mci, old)
+		if err != nil {
+			return err
+		}
+	}
+	err := dbPutAsset(library.tx, address, buf)
+	if err != nil {
+		return err
+	}
+
+	err = dbPutPublisherIndex(library.tx, asset.PublisherAddress, address)
+	if err != nil {
+		return err
+	}
+	for _, contractDef := range asset.Contracts {
+		err = dbAddContractRef(library.tx, contractDef.Address, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dbPutMCIIndex(library.tx, mci, mciIndexAsset, address)
+}
+
+//unindexAsset removes the publisher and MCI index entries of a stored asset and releases the contracts it refers to
+//old is the stored value of asset, if it can't be decoded the index entries of asset are searched by key
+func (library *ContractLibrary) unindexAsset(asset hash.HashType, old []byte) error {
+	issueMessage := structure.NewIssueMessage()
+	if len(old) < 8 || issueMessage.Deserialize(old[8:]) != nil {
+		err := dbDeletePublisherIndexOf(library.tx, asset)
+		if err != nil {
+			return err
+		}
+		return dbDeleteMCIIndexOf(library.tx, mciIndexAsset, asset)
+	}
+
+	err := dbDeleteMCIIndex(library.tx, mciIndexKey(binary.BigEndian.Uint64(old), mciIndexAsset, asset))
+	if err != nil {
+		return err
+	}
+	for _, contractDef := range issueMessage.Contracts {
+		err = dbAddContractRef(library.tx, contractDef.Address, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dbDeletePublisherIndex(library.tx, issueMessage.PublisherAddress, asset)
+}
+
+//ListAssetsByPublisher lists hashes of assets issued by publisher address
+func (library *ContractLibrary) ListAssetsByPublisher(publisher hash.HashType) ([]hash.HashType, error) {
+	return dbListPublisherIndex(library.tx, publisher), nil
+}
 
+//ListAssetsByContract lists hashes of assets associated with the contract address
+func (library *ContractLibrary) ListAssetsByContract(contract hash.HashType) ([]hash.HashType, error) {
+	return dbListContractUsageIndex(library.tx, contract), nil
 }
 
 //LoadAsset is to read asset
 func (library *ContractLibrary) LoadAsset(assetHash hash.HashType) (*structure.IssueMessage, uint64, error) {
-	var buf []byte
-
 	data, err := dbFetchAsset(library.tx, assetHash)
-	buf = data
-
 	if err != nil {
 		return nil, 0, err
 	}
+	if len(data) < 8 {
+		return nil, 0, corruptRecordError(assetHash, nil)
+	}
 	mci := binary.BigEndian.Uint64(data)
 
 	issueMessage := structure.NewIssueMessage()
-	issueMessage.Deserialize(buf[8:])
+	err = issueMessage.Deserialize(data[8:])
+	if err != nil {
+		return nil, 0, corruptRecordError(assetHash, err)
+	}
+
+	return issueMessage, mci, nil
+}
 
-	return issueMessage, mci, err
+//ListAssets lists hashes of all assets
+func (library *ContractLibrary) ListAssets() ([]hash.HashType, error) {
+	assets, _ := dbListAssetsPaged(library.tx, nil, -1)
+	return assets, nil
 }
 
-//RemoveAsset is to remove asset
+//ListAssetsPaged lists at most limit asset hashes from cursor, it pages like ListContractsPaged
+func (library *ContractLibrary) ListAssetsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error) {
+	if limit <= 0 {
+		return nil, nil, errors.Errorf("invalid page limit %d", limit)
+	}
+	assets, next := dbListAssetsPaged(library.tx, cursor, limit)
+	return assets, next, nil
+}
+
+//RemoveAsset is to remove asset with the contract definitions associated with it
 func (library *ContractLibrary) RemoveAsset(asset hash.HashType) error {
 	if library.readOnly {
 		return errors.Errorf(writePermissionsError)
 	}
+	if !dbHasAsset(library.tx, asset) {
+		return nil
+	}
 
-	err := dbDeleteAsset(library.tx, asset)
+	old, _ := dbFetchAsset(library.tx, asset)
+	err := library.unindexAsset(asset, old)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteAsset(library.tx, asset)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteUndoOf(library.tx, mciIndexAsset, asset)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return dbDeleteAssetContracts(library.tx, asset)
 }
 
 //HasAsset is to check if current asset is exist in the database
@@ -209,6 +408,351 @@ func (library *ContractLibrary) HasAssetContract(asset hash.HashType, addr hash.
 
 }
 
+//RollbackTo removes contracts, assets, contract definitions and oracles stored above mci, with the contract definitions of removed assets
+//a contract keeps the MCI it was first stored at, an asset, a definition or an oracle overwritten above mci is restored to the value it had at mci
+func (library *ContractLibrary) RollbackTo(mci uint64) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	if mci == math.MaxUint64 {
+		return nil
+	}
+
+	for _, indexKey := range dbFetchMCIIndexAbove(library.tx, mci) {
+		var err error
+		key := indexKey[9:]
+		switch indexKey[8] {
+		case mciIndexContract:
+			err = dbDeleteContract(library.tx, key)
+		default:
+			err = library.rollbackRecord(indexKey[8], key, mci)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = dbDeleteMCIIndex(library.tx, indexKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//saveUndo keeps old, the value of the record of kind at key before it is overwritten at mci
+//only an overwrite at a higher MCI is kept, so the MCIs of the values along the undo records of a record go down
+func (library *ContractLibrary) saveUndo(kind byte, key []byte, mci uint64, old []byte) error {
+	if len(old) < 8 || binary.BigEndian.Uint64(old) >= mci || dbHasUndo(library.tx, kind, key, mci) {
+		return nil
+	}
+	return dbPutUndo(library.tx, kind, key, mci, old)
+}
+
+//rollbackRecord restores the asset, asset contract or oracle at key to the value it had at mci by its undo records
+//the record is removed if it has no value at mci, or if it can't be decoded
+func (library *ContractLibrary) rollbackRecord(kind byte, key []byte, mci uint64) error {
+	//an asset contract key is the asset hash followed by the contract address of the same size
+	half := len(key) / 2
+	remove := func() error {
+		switch kind {
+		case mciIndexAsset:
+			return library.RemoveAsset(key)
+		case mciIndexAssetContract:
+			return library.RemoveAssetContract(key[:half], key[half:])
+		default:
+			return library.RemoveOracle(key)
+		}
+	}
+
+	for {
+		var value []byte
+		switch kind {
+		case mciIndexAsset:
+			value, _ = dbFetchAsset(library.tx, key)
+		case mciIndexAssetContract:
+			value, _ = dbFetchAssetContract(library.tx, key)
+		case mciIndexOracle:
+			value, _ = dbFetchOracle(library.tx, key)
+		}
+		if len(value) >= 8 && binary.BigEndian.Uint64(value) <= mci {
+			return nil
+		}
+
+		var prev []byte
+		if len(value) >= 8 {
+			prev = dbFetchUndo(library.tx, kind, key, binary.BigEndian.Uint64(value))
+		}
+		if prev == nil {
+			return remove()
+		}
+		err := dbDeleteUndo(library.tx, kind, key, binary.BigEndian.Uint64(value))
+		if err != nil {
+			return err
+		}
+
+		prevMCI := binary.BigEndian.Uint64(prev)
+		switch kind {
+		case mciIndexAsset:
+			issueMessage := structure.NewIssueMessage()
+			if issueMessage.Deserialize(prev[8:]) != nil {
+				return remove()
+			}
+			err = library.putAsset(key, issueMessage, prevMCI, false)
+		case mciIndexAssetContract:
+			contractDef := new(structure.ContractDef)
+			if contractDef.Deserialize(prev[8:]) != nil {
+				return remove()
+			}
+			err = library.putAssetContractDef(key[:half], contractDef, prevMCI, false)
+		default:
+			oracle := new(Oracle)
+			if oracle.Deserialize(prev[8:]) != nil {
+				return remove()
+			}
+			err = library.putOracle(oracle, prevMCI, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//SaveOracle is to store oracle identity registered at mci, the oracle is keyed by its ID
+func (library *ContractLibrary) SaveOracle(oracle *Oracle, mci uint64) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	return library.putOracle(oracle, mci, true)
+}
+
+//putOracle stores oracle at mci, the value it overwrites is kept in an undo record if undo is set
+func (library *ContractLibrary) putOracle(oracle *Oracle, mci uint64, undo bool) error {
+	id := oracle.ID()
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+	buf = append(buf, oracle.Serialize()...)
+
+	old, _ := dbFetchOracle(library.tx, id)
+	if undo {
+		err := library.saveUndo(mciIndexOracle, id, mci, old)
+		if err != nil {
+			return err
+		}
+	}
+	err := dbPutOracle(library.tx, id, buf)
+	if err != nil {
+		return err
+	}
+	return dbReindexMCI(library.tx, mci, mciIndexOracle, id, old)
+}
+
+//LoadOracle is to read oracle identity
+func (library *ContractLibrary) LoadOracle(id hash.HashType) (*Oracle, error) {
+	data, err := dbFetchOracle(library.tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 8 {
+		return nil, corruptRecordError(id, nil)
+	}
+	oracle := new(Oracle)
+	err = oracle.Deserialize(data[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	return oracle, nil
+}
+
+//FetchOracle returns the public key with scheme header of oracle, it returns nil if the oracle doesn't exist
+//it is used as the FetchOracle callback of VM context
+func (library *ContractLibrary) FetchOracle(id hash.HashType) []byte {
+	return fetchOracle(library, id)
+}
+
+//HasOracle is to check if the oracle is exist in the database
+func (library *ContractLibrary) HasOracle(id hash.HashType) bool {
+	return dbHasOracle(library.tx, id)
+}
+
+//RemoveOracle is to remove oracle identity and its MCI index
+func (library *ContractLibrary) RemoveOracle(id hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	if !dbHasOracle(library.tx, id) {
+		return nil
+	}
+
+	old, _ := dbFetchOracle(library.tx, id)
+	err := dbDeleteOracle(library.tx, id)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteUndoOf(library.tx, mciIndexOracle, id)
+	if err != nil {
+		return err
+	}
+	if len(old) < 8 {
+		return dbDeleteMCIIndexOf(library.tx, mciIndexOracle, id)
+	}
+	return dbDeleteMCIIndex(library.tx, mciIndexKey(binary.BigEndian.Uint64(old), mciIndexOracle, id))
+}
+
+//QuarantinedRecord is a corrupted record moved out of its bucket
+type QuarantinedRecord struct {
+	Bucket string
+	Key    hash.HashType
+	Value  []byte
+}
+
+//QuarantineContract moves a corrupted contract to the quarantine bucket, so it is no longer loaded
+func (library *ContractLibrary) QuarantineContract(address hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	record, err := dbQuarantineContract(library.tx, address)
+	if err != nil || record == nil {
+		return err
+	}
+
+	//the MCI stored in a corrupted record can't be trusted, so the index is searched by key
+	return dbDeleteMCIIndexOf(library.tx, mciIndexContract, address)
+}
+
+//QuarantineAsset moves a corrupted asset to the quarantine bucket, the contract definitions of asset are kept
+func (library *ContractLibrary) QuarantineAsset(asset hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	record, err := dbQuarantineAsset(library.tx, asset)
+	if err != nil || record == nil {
+		return err
+	}
+
+	err = dbDeletePublisherIndexOf(library.tx, asset)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteUndoOf(library.tx, mciIndexAsset, asset)
+	if err != nil {
+		return err
+	}
+
+	return dbDeleteMCIIndexOf(library.tx, mciIndexAsset, asset)
+}
+
+//QuarantineAssetContract moves a corrupted contract definition associated with asset to the quarantine bucket
+func (library *ContractLibrary) QuarantineAssetContract(asset hash.HashType, address hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	key := append(append([]byte(nil), asset...), address...)
+	record, err := dbQuarantineAssetContract(library.tx, key)
+	if err != nil || record == nil {
+		return err
+	}
+
+	err = dbDeleteContractUsageIndex(library.tx, address, asset)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteMCIIndexOf(library.tx, mciIndexAssetContract, key)
+	if err != nil {
+		return err
+	}
+	err = dbDeleteUndoOf(library.tx, mciIndexAssetContract, key)
+	if err != nil {
+		return err
+	}
+
+	return dbAddContractRef(library.tx, address, -1)
+}
+
+//ListQuarantined lists the quarantined records
+func (library *ContractLibrary) ListQuarantined() ([]*QuarantinedRecord, error) {
+	return dbListQuarantine(library.tx)
+}
+
+//ContractRefCount returns the number of stored asset contract definitions, assets and retains referring to address
+func (library *ContractLibrary) ContractRefCount(address hash.HashType) uint32 {
+	return dbFetchContractRefCount(library.tx, address) + dbFetchContractRetainCount(library.tx, address)
+}
+
+//RetainContract adds a reference to the contract from outside the library, such as a restrict of an output
+//a retained contract is not collected until it is released, the output store retains the contracts of the outputs it stores
+func (library *ContractLibrary) RetainContract(address hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	return dbAddContractRetain(library.tx, address, 1)
+}
+
+//ReleaseContract removes a reference added by RetainContract, a contract which is not retained is refused
+//the references of asset contract definitions and assets are only removed with them
+func (library *ContractLibrary) ReleaseContract(address hash.HashType) error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	if dbFetchContractRetainCount(library.tx, address) == 0 {
+		return errors.Errorf("contract %v is not retained", address)
+	}
+
+	return dbAddContractRetain(library.tx, address, -1)
+}
+
+//EnableGarbageCollection records that the contracts of every stored output are retained by RetainContract
+//the output store calls it once it has retained the contracts of the outputs stored before, such as after a migration
+func (library *ContractLibrary) EnableGarbageCollection() error {
+	if library.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+
+	return dbPutGarbageCollection(library.tx)
+}
+
+//CollectGarbage removes the unreferenced contracts stored before beforeMCI and returns their addresses
+//it is refused until EnableGarbageCollection is called, as the contracts of outputs would be collected otherwise
+//corrupted contracts are skipped, they are found by CheckConsistency
+func (library *ContractLibrary) CollectGarbage(beforeMCI uint64) ([]hash.HashType, error) {
+	if library.readOnly {
+		return nil, errors.Errorf(writePermissionsError)
+	}
+	if !dbFetchGarbageCollection(library.tx) {
+		return nil, NewSmartContractError(ErrGCDisabled, "Contract references of outputs are not counted", nil)
+	}
+
+	garbage := make([]hash.HashType, 0)
+	err := dbForEachIntactContract(library.tx, func(k, v []byte) error {
+		if len(v) < 8 || binary.BigEndian.Uint64(v) >= beforeMCI {
+			return nil
+		}
+		if library.ContractRefCount(k) == 0 {
+			garbage = append(garbage, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range garbage {
+		err = library.RemoveContract(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return garbage, nil
+}
+
 //NewContractLibrary is to create a new object to access contract database
 func NewContractLibrary(tx database.Tx, readOnly bool) *ContractLibrary {
 	return &ContractLibrary{
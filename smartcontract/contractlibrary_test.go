@@ -25,6 +25,7 @@ import (
 	_ "github.com/SHDMT/gravity/infrastructure/database/badgerdb"
 	"github.com/SHDMT/gravity/platform/consensus/genesis"
 	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
 )
 
 const (
@@ -292,3 +293,479 @@ func TestContractLibrary_SaveAsset(t *testing.T) {
 	})
 
 }
+
+func TestContractLibrary_SaveOracle(t *testing.T) {
+	db, err := createOrOpenDB("./testSaveOracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+		oracle := &Oracle{
+			Scheme:      2,
+			PubKey:      FakeRandomHash(),
+			Description: "BTC/USD price feed",
+		}
+
+		err = library.SaveOracle(oracle, 1)
+		if err != nil {
+			t.Error("Can't save oracle, ", err)
+		}
+
+		if !library.HasOracle(oracle.ID()) {
+			t.Error("Can't find oracle in db.")
+		}
+
+		dbOracle, err := library.LoadOracle(oracle.ID())
+		if err != nil {
+			t.Error("Can't load oracle, ", err)
+		}
+		if !reflect.DeepEqual(oracle, dbOracle) {
+			t.Error("Save or load error, oracle is not equal to dbOracle.")
+		}
+
+		pk := library.FetchOracle(oracle.ID())
+		if !reflect.DeepEqual(pk, append([]byte{2}, oracle.PubKey...)) {
+			t.Error("Fetch oracle returns wrong public key.")
+		}
+
+		//an oracle registered again above the rollback mci is restored, it is removed by rolling back its first registration
+		library.SaveOracle(&Oracle{Scheme: 2, PubKey: oracle.PubKey}, 2)
+		library.RollbackTo(1)
+		dbOracle, err = library.LoadOracle(oracle.ID())
+		if err != nil || !reflect.DeepEqual(oracle, dbOracle) {
+			t.Error("Oracle registered again above mci should be restored.")
+		}
+		library.RollbackTo(0)
+		if library.HasOracle(oracle.ID()) {
+			t.Error("Oracle registered above mci should be rolled back.")
+		}
+
+		library.SaveOracle(oracle, 1)
+		err = library.RemoveOracle(oracle.ID())
+		if err != nil {
+			t.Error("Can't remove oracle, ", err)
+		}
+		if library.HasOracle(oracle.ID()) || library.FetchOracle(oracle.ID()) != nil {
+			t.Error("Find oracle, Should can't find oracle in db.")
+		}
+
+		readOnly := NewContractLibrary(tx, true)
+		if readOnly.SaveOracle(oracle, 1) == nil {
+			t.Error("Read only library should not save oracle.")
+		}
+
+		return nil
+	})
+}
+
+func TestContractLibrary_RollbackTo(t *testing.T) {
+	db, err := createOrOpenDB("./testRollbackTo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+		contract0 := CreateContract0()
+		contract1 := CreateContract1()
+		asset0 := FakeRandomHash()
+		asset1 := FakeRandomHash()
+
+		library.SaveContract(contract0, 10)
+		library.SaveContract(contract1, 20)
+		library.SaveAsset(asset0, FakeIssueMessage(), 10)
+		library.SaveAsset(asset1, FakeIssueMessage(), 20)
+		library.SaveAssetContractDefAt(asset0, CreateRandomContractDef(contract0.CalcAddress()), 10)
+		//a definition saved without mci is stored at the mci of its asset
+		library.SaveAssetContractDef(asset1, CreateRandomContractDef(contract1.CalcAddress()))
+
+		err = library.RollbackTo(15)
+		if err != nil {
+			t.Error("Can't rollback, ", err)
+		}
+
+		if !library.HasContract(contract0.CalcAddress()) || !library.HasAsset(asset0) ||
+			!library.HasAssetContract(asset0, contract0.CalcAddress()) {
+			t.Error("Records stored at mci 10 should be kept.")
+		}
+		if library.HasContract(contract1.CalcAddress()) || library.HasAsset(asset1) ||
+			library.HasAssetContract(asset1, contract1.CalcAddress()) {
+			t.Error("Records stored at mci 20 should be removed.")
+		}
+
+		//a contract stored again keeps its first mci, a definition is rolled back by its own mci
+		library.SaveContract(contract0, 30)
+		def := CreateRandomContractDef(FakeRandomHash())
+		library.SaveAssetContractDefAt(asset0, def, 30)
+		err = library.RollbackTo(25)
+		if err != nil {
+			t.Error("Can't rollback, ", err)
+		}
+		if _, mci, err := library.LoadContract(contract0.CalcAddress()); err != nil || mci != 10 {
+			t.Error("Contract0 stored again at mci 30 should be kept at mci 10.")
+		}
+		if library.HasAssetContract(asset0, def.Address) || !library.HasAssetContract(asset0, contract0.CalcAddress()) {
+			t.Error("Only the definition stored at mci 30 should be removed.")
+		}
+
+		err = library.RollbackTo(0)
+		if err != nil {
+			t.Error("Can't rollback, ", err)
+		}
+		if library.HasAsset(asset0) {
+			t.Error("Asset0 should be removed.")
+		}
+
+		readOnly := NewContractLibrary(tx, true)
+		if readOnly.RollbackTo(0) == nil {
+			t.Error("Read only library should not rollback.")
+		}
+
+		return nil
+	})
+}
+
+func TestContractLibrary_ListPaged(t *testing.T) {
+	db, err := createOrOpenDB("./testListPaged")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		contracts := make(map[string]bool)
+		for i := 0; i < 7; i++ {
+			contract := CreateContract0()
+			contract.Version = uint32(i)
+			library.SaveContract(contract, uint64(i))
+			contracts[string(contract.CalcAddress())] = true
+		}
+
+		//page through all contracts 3 by 3
+		var cursor hash.HashType
+		pages := 0
+		listed := make(map[string]bool)
+		for {
+			page, next, err := library.ListContractsPaged(cursor, 3)
+			if err != nil {
+				t.Fatal("Can't list contracts, ", err)
+			}
+			pages++
+			for _, addr := range page {
+				listed[string(addr)] = true
+			}
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+		if pages != 3 || !reflect.DeepEqual(contracts, listed) {
+			t.Errorf("List contracts paged error, pages %d, listed %d contracts.", pages, len(listed))
+		}
+		if _, _, err := library.ListContractsPaged(nil, 0); err == nil {
+			t.Error("Zero limit should be refused.")
+		}
+
+		asset0 := FakeRandomHash()
+		asset1 := FakeRandomHash()
+		library.SaveAsset(asset0, FakeIssueMessage(), 1)
+		library.SaveAsset(asset1, FakeIssueMessage(), 2)
+		assets, err := library.ListAssets()
+		if err != nil || len(assets) != 2 {
+			t.Errorf("List assets error, %d assets, %v", len(assets), err)
+		}
+		page, next, err := library.ListAssetsPaged(nil, 1)
+		if err != nil || len(page) != 1 || next == nil {
+			t.Errorf("List assets paged error, %d assets, %v", len(page), err)
+		}
+
+		def0 := CreateRandomContractDef(FakeRandomHash())
+		def1 := CreateRandomContractDef(FakeRandomHash())
+		library.SaveAssetContractDefAt(asset0, def0, 1)
+		library.SaveAssetContractDefAt(asset0, def1, 1)
+		library.SaveAssetContractDefAt(asset1, CreateRandomContractDef(FakeRandomHash()), 2)
+		defs, err := library.ListAssetContractDefs(asset0)
+		if err != nil || len(defs) != 2 {
+			t.Fatalf("List asset contract defs error, %d defs, %v", len(defs), err)
+		}
+		for _, def := range defs {
+			if !reflect.DeepEqual(def, def0) && !reflect.DeepEqual(def, def1) {
+				t.Error("Listed contract def is not saved for asset0.")
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestContractLibrary_AssetIndexes(t *testing.T) {
+	db, err := createOrOpenDB("./testAssetIndexes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		asset0, asset1, asset2 := FakeRandomHash(), FakeRandomHash(), FakeRandomHash()
+		msg0 := FakeIssueMessage()
+		msg1 := FakeIssueMessage()
+		msg1.PublisherAddress = msg0.PublisherAddress
+		msg2 := FakeIssueMessage()
+		library.SaveAsset(asset0, msg0, 1)
+		library.SaveAsset(asset1, msg1, 2)
+		library.SaveAsset(asset2, msg2, 3)
+
+		assets, err := library.ListAssetsByPublisher(msg0.PublisherAddress)
+		if err != nil || len(assets) != 2 {
+			t.Errorf("List assets by publisher error, %d assets, %v", len(assets), err)
+		}
+
+		contract := FakeRandomHash()
+		library.SaveAssetContractDefAt(asset0, CreateRandomContractDef(contract), 1)
+		library.SaveAssetContractDefAt(asset2, CreateRandomContractDef(contract), 3)
+		library.SaveAssetContractDefAt(asset1, CreateRandomContractDef(FakeRandomHash()), 2)
+		assets, err = library.ListAssetsByContract(contract)
+		if err != nil || len(assets) != 2 {
+			t.Errorf("List assets by contract error, %d assets, %v", len(assets), err)
+		}
+
+		//the indexes follow removal
+		library.RemoveAsset(asset0)
+		library.RemoveAssetContract(asset2, contract)
+		assets, _ = library.ListAssetsByPublisher(msg0.PublisherAddress)
+		if len(assets) != 1 || !reflect.DeepEqual(assets[0], asset1) {
+			t.Error("Removed asset0 should not be indexed by publisher.")
+		}
+		assets, _ = library.ListAssetsByContract(contract)
+		if len(assets) != 0 {
+			t.Error("Removed asset0 and asset contract of asset2 should not be indexed.")
+		}
+
+		//an asset saved again is indexed by its new publisher
+		msg1.PublisherAddress = FakeRandomHash()
+		library.SaveAsset(asset1, msg1, 4)
+		assets, _ = library.ListAssetsByPublisher(msg0.PublisherAddress)
+		if len(assets) != 0 {
+			t.Error("Asset1 should not be indexed by its old publisher.")
+		}
+
+		//the indexes follow rollback
+		library.SaveAssetContractDefAt(asset1, CreateRandomContractDef(contract), 4)
+		library.RollbackTo(3)
+		assets, _ = library.ListAssetsByPublisher(msg1.PublisherAddress)
+		if len(assets) != 0 {
+			t.Error("Rolled back asset1 should not be indexed by publisher.")
+		}
+		assets, _ = library.ListAssetsByContract(contract)
+		for _, asset := range assets {
+			if reflect.DeepEqual(asset, asset1) {
+				t.Error("Contracts of rolled back asset1 should not be indexed.")
+			}
+		}
+
+		//an undecodable asset is overwritten, its index entries are searched by key
+		tx.Data().Bucket(dbnamespace.AssetBucket).Put(asset2, withChecksum([]byte{1, 2, 3}))
+		err = library.SaveAsset(asset2, msg0, 5)
+		if err != nil {
+			t.Errorf("Can't save over an undecodable asset, %v", err)
+		}
+		assets, _ = library.ListAssetsByPublisher(msg2.PublisherAddress)
+		if len(assets) != 0 {
+			t.Error("Overwritten asset2 should not be indexed by its old publisher.")
+		}
+		library.RollbackTo(4)
+		if library.HasAsset(asset2) {
+			t.Error("Asset2 stored again at mci 5 should be rolled back.")
+		}
+
+		return nil
+	})
+}
+
+func TestContractLibrary_CorruptRecord(t *testing.T) {
+	db, err := createOrOpenDB("./testCorruptRecord")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		contract := CreateContract0()
+		address := contract.CalcAddress()
+		asset := FakeRandomHash()
+		msg := FakeIssueMessage()
+		def := CreateRandomContractDef(address)
+		library.SaveContract(contract, 1)
+		library.SaveAsset(asset, msg, 1)
+		library.SaveAssetContractDefAt(asset, def, 1)
+
+		//a flipped byte fails the checksum
+		data := tx.Data()
+		record := append([]byte(nil), data.Bucket(dbnamespace.ContractBucket).Get(address)...)
+		record[10] ^= 0xff
+		data.Bucket(dbnamespace.ContractBucket).Put(address, record)
+		_, _, err := library.LoadContract(address)
+		if err == nil || !IsCorruptRecordError(err.(*SmartContractError)) {
+			t.Errorf("Corrupted contract should fail to load, %v", err)
+		}
+
+		//a record too short for its MCI passes the checksum but fails the length check
+		data.Bucket(dbnamespace.AssetBucket).Put(asset, withChecksum([]byte{1, 2, 3}))
+		_, _, err = library.LoadAsset(asset)
+		if err == nil || !IsCorruptRecordError(err.(*SmartContractError)) {
+			t.Errorf("Truncated asset should fail to load, %v", err)
+		}
+
+		//a record failing to decode
+		key := append(append([]byte(nil), asset...), address...)
+		data.Bucket(dbnamespace.AssetContractBucket).Put(key, withChecksum([]byte("not a contract def")))
+		_, err = library.LoadAssetContractDef(asset, address)
+		if err == nil || !IsCorruptRecordError(err.(*SmartContractError)) {
+			t.Errorf("Undecodable contract def should fail to load, %v", err)
+		}
+		_, err = library.ListAssetContractDefs(asset)
+		if err == nil {
+			t.Error("Undecodable contract def should fail to list.")
+		}
+
+		//quarantined records are moved out with their index entries
+		library.QuarantineContract(address)
+		library.QuarantineAsset(asset)
+		library.QuarantineAssetContract(asset, address)
+		if library.HasContract(address) || library.HasAsset(asset) || library.HasAssetContract(asset, address) {
+			t.Error("Quarantined records should be removed.")
+		}
+		assets, _ := library.ListAssetsByPublisher(msg.PublisherAddress)
+		if len(assets) != 0 {
+			t.Error("Quarantined asset should not be indexed by publisher.")
+		}
+		assets, _ = library.ListAssetsByContract(address)
+		if len(assets) != 0 {
+			t.Error("Quarantined asset contract should not be indexed.")
+		}
+		if len(dbFetchMCIIndexAbove(tx, 0)) != 0 {
+			t.Error("Quarantined records should not be indexed by MCI.")
+		}
+
+		records, err := library.ListQuarantined()
+		if err != nil || len(records) != 3 {
+			t.Fatalf("List quarantined error, %d records, %v", len(records), err)
+		}
+		for _, r := range records {
+			if r.Bucket == string(dbnamespace.ContractBucket) && !reflect.DeepEqual(r.Value, record) {
+				t.Error("Quarantined contract should keep its raw record.")
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestContractLibrary_CollectGarbage(t *testing.T) {
+	db, err := createOrOpenDB("./testCollectGarbage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CreateSmartContractBucket(db)
+
+	db.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+
+		contract0, contract1 := CreateContract0(), CreateContract1()
+		address0, address1 := contract0.CalcAddress(), contract1.CalcAddress()
+		library.SaveContract(contract0, 1)
+		library.SaveContract(contract1, 1)
+
+		//contract0 is referenced by the asset and its definition
+		asset := FakeRandomHash()
+		def := CreateRandomContractDef(address0)
+		msg := FakeIssueMessage()
+		msg.Contracts = []*structure.ContractDef{def}
+		library.SaveAsset(asset, msg, 2)
+		library.SaveAssetContractDefAt(asset, def, 2)
+		library.SaveAssetContractDefAt(asset, def, 2)
+		if library.ContractRefCount(address0) != 2 {
+			t.Errorf("Contract0 has %d references, expect 2.", library.ContractRefCount(address0))
+		}
+		//contract0 is not retained, so releases can't drop the references of the asset and its definition
+		for i := 0; i < 2; i++ {
+			if library.ReleaseContract(address0) == nil {
+				t.Error("Contract which is not retained should not be released.")
+			}
+		}
+
+		//garbage collection is refused until the contracts of outputs are retained
+		if _, err := library.CollectGarbage(10); err == nil {
+			t.Error("Garbage collection should be refused before it is enabled.")
+		}
+		library.EnableGarbageCollection()
+
+		//a corrupted contract is skipped
+		corrupted := FakeRandomHash()
+		tx.Data().Bucket(dbnamespace.ContractBucket).Put(corrupted, []byte("corrupted"))
+
+		//contract1 is retained by an output
+		library.RetainContract(address1)
+		removed, err := library.CollectGarbage(10)
+		if err != nil || len(removed) != 0 || !library.HasContract(address0) {
+			t.Errorf("Referenced contracts should not be collected, %v", err)
+		}
+
+		library.ReleaseContract(address1)
+		if library.ReleaseContract(address1) == nil {
+			t.Error("Unreferenced contract should not be released.")
+		}
+		removed, _ = library.CollectGarbage(1)
+		if len(removed) != 0 {
+			t.Error("Contracts in the retention window should not be collected.")
+		}
+		removed, _ = library.CollectGarbage(2)
+		if len(removed) != 1 || !reflect.DeepEqual(removed[0], address1) || library.HasContract(address1) {
+			t.Error("Unreferenced contract1 should be collected.")
+		}
+		if !library.HasContract(corrupted) {
+			t.Error("Corrupted contract should be left for the consistency check.")
+		}
+
+		//removing the asset and its definition releases contract0
+		library.RemoveAssetContract(asset, address0)
+		library.RemoveAsset(asset)
+		if library.ContractRefCount(address0) != 0 {
+			t.Errorf("Contract0 has %d references, expect 0.", library.ContractRefCount(address0))
+		}
+		removed, _ = library.CollectGarbage(2)
+		if len(removed) != 1 || library.HasContract(address0) {
+			t.Error("Released contract0 should be collected.")
+		}
+
+		//rollback releases the references of removed assets
+		library.SaveContract(contract0, 1)
+		library.SaveAsset(asset, msg, 3)
+		library.SaveAssetContractDefAt(asset, def, 3)
+		library.RollbackTo(2)
+		if library.ContractRefCount(address0) != 0 {
+			t.Errorf("Contract0 has %d references after rollback, expect 0.", library.ContractRefCount(address0))
+		}
+
+		if _, err := NewContractLibrary(tx, true).CollectGarbage(2); err == nil {
+			t.Error("Read-only library should not collect garbage.")
+		}
+		return nil
+	})
+}
@@ -32,6 +32,14 @@ const (
 	ErrDeleteDB
 	//ErrForEachDB is traversal failure
 	ErrForEachDB
+	//ErrMigrateDB is a failed storage migration
+	ErrMigrateDB
+	//ErrCorruptRecord is a stored record failing its length, checksum or decoding check
+	ErrCorruptRecord
+	//ErrSnapshot is a malformed or corrupted snapshot
+	ErrSnapshot
+	//ErrGCDisabled is a garbage collection refused before the contract references of outputs are counted
+	ErrGCDisabled
 )
 
 //Error is the error type converted to string type
@@ -56,6 +64,11 @@ func IsNotFoundFormDBError(smartContractError *SmartContractError) bool {
 	return smartContractError.isErrorType(ErrNotFoundFormDB)
 }
 
+//IsCorruptRecordError is that the stored record is corrupted
+func IsCorruptRecordError(smartContractError *SmartContractError) bool {
+	return smartContractError.isErrorType(ErrCorruptRecord)
+}
+
 func (e *SmartContractError) isErrorType(code uint32) bool {
 	if e.errorCode == code {
 		return true
@@ -21,4 +21,26 @@ var (
 	AssetContractBucket = []byte("assetContract")
 	//AssetBucket is a database table used to store assets
 	AssetBucket = []byte("asset")
+	//OracleBucket is a database table used to store oracle identities
+	OracleBucket = []byte("oracle")
+	//MCIIndexBucket is a database table used to index contracts, assets, contract definitions and oracles by the MCI they are stored at
+	MCIIndexBucket = []byte("mciIndex")
+	//PublisherIndexBucket is a database table used to index assets by publisher address
+	PublisherIndexBucket = []byte("publisherIndex")
+	//ContractUsageIndexBucket is a database table used to index assets by the contracts associated with them
+	ContractUsageIndexBucket = []byte("contractUsageIndex")
+	//ContractRefCountBucket is a database table used to count the asset contract definitions and assets referring to each contract address
+	ContractRefCountBucket = []byte("contractRefCount")
+	//ContractRetainBucket is a database table used to count the retains of each contract address from outside the library
+	ContractRetainBucket = []byte("contractRetain")
+	//UndoBucket is a database table used to keep the previous values of assets, contract definitions and oracles overwritten at a higher MCI
+	UndoBucket = []byte("undo")
+	//QuarantineBucket is a database table used to keep corrupted records moved out of the other tables
+	QuarantineBucket = []byte("quarantine")
+	//MetaBucket is a database table used to store the storage version of smart contract buckets
+	MetaBucket = []byte("smartContractMeta")
+	//StorageVersionKey is the key of storage version in MetaBucket
+	StorageVersionKey = []byte("version")
+	//GarbageCollectionKey is the key in MetaBucket marking the contract references of stored outputs as counted
+	GarbageCollectionKey = []byte("garbageCollection")
 )
@@ -15,17 +15,54 @@
 package smartcontract
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+
 	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
 	"github.com/SHDMT/gravity/infrastructure/database"
 	"github.com/SHDMT/gravity/infrastructure/log"
 	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
 )
 
+//checksumSize is the size of the CRC-32C checksum appended to contract, asset and asset contract records
+const checksumSize = 4
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+//withChecksum returns value followed by its checksum
+func withChecksum(value []byte) []byte {
+	buf := make([]byte, len(value)+checksumSize)
+	copy(buf, value)
+	binary.BigEndian.PutUint32(buf[len(value):], crc32.Checksum(value, checksumTable))
+	return buf
+}
+
+//verifyChecksum checks the checksum of the record stored at key and returns the record without it
+func verifyChecksum(key, record []byte) ([]byte, error) {
+	if len(record) < checksumSize {
+		errString := fmt.Sprintf("Record %v is too short", key)
+		return nil, NewSmartContractError(ErrCorruptRecord, errString, nil)
+	}
+	value := record[:len(record)-checksumSize]
+	if crc32.Checksum(value, checksumTable) != binary.BigEndian.Uint32(record[len(value):]) {
+		errString := fmt.Sprintf("Checksum mismatch of record %v", key)
+		return nil, NewSmartContractError(ErrCorruptRecord, errString, nil)
+	}
+	return value, nil
+}
+
+//corruptRecordError is the error of a record at key failing to decode
+func corruptRecordError(key []byte, err error) error {
+	errString := fmt.Sprintf("Failed to decode record %v", key)
+	return NewSmartContractError(ErrCorruptRecord, errString, err)
+}
+
 func dbPutContract(dbTx database.Tx, key, value []byte) error {
 	contractBucket := dbTx.Data().Bucket(dbnamespace.ContractBucket)
 
-	err := contractBucket.Put(key, value)
+	err := contractBucket.Put(key, withChecksum(value))
 	if err != nil {
 		errString := fmt.Sprintf("Failed to put contract %v", key)
 		return NewSmartContractError(ErrPutDB, errString, err)
@@ -42,7 +79,7 @@ func dbFetchContract(dbTx database.Tx, key []byte) ([]byte, error) {
 		return nil, NewSmartContractError(ErrNotFoundFormDB, errString, nil)
 	}
 
-	return value, nil
+	return verifyChecksum(key, value)
 }
 
 func dbDeleteContract(dbTx database.Tx, key []byte) error {
@@ -79,7 +116,7 @@ func dbListAllContracts(dbTx database.Tx) ([]hash.HashType, error) {
 func dbPutAssetContract(dbTx database.Tx, key, value []byte) error {
 	assetContractBucket := dbTx.Data().Bucket(dbnamespace.AssetContractBucket)
 
-	err := assetContractBucket.Put(key, value)
+	err := assetContractBucket.Put(key, withChecksum(value))
 	if err != nil {
 		errString := fmt.Sprintf("Failed to put assetContract %v", key)
 		return NewSmartContractError(ErrPutDB, errString, err)
@@ -96,7 +133,7 @@ func dbFetchAssetContract(dbTx database.Tx, key []byte) ([]byte, error) {
 		return nil, NewSmartContractError(ErrNotFoundFormDB, errString, nil)
 	}
 
-	return value, nil
+	return verifyChecksum(key, value)
 }
 
 func dbDeleteAssetContract(dbTx database.Tx, key []byte) error {
@@ -119,7 +156,7 @@ func dbHasAssetContract(dbTx database.Tx, key []byte) bool {
 func dbPutAsset(dbTx database.Tx, key, value []byte) error {
 	assetBucket := dbTx.Data().Bucket(dbnamespace.AssetBucket)
 
-	err := assetBucket.Put(key, value)
+	err := assetBucket.Put(key, withChecksum(value))
 	if err != nil {
 		errString := fmt.Sprintf("Failed to put asset %v", key)
 		return NewSmartContractError(ErrPutDB, errString, err)
@@ -136,7 +173,7 @@ func dbFetchAsset(dbTx database.Tx, key []byte) ([]byte, error) {
 		return nil, NewSmartContractError(ErrNotFoundFormDB, errString, nil)
 	}
 
-	return value, nil
+	return verifyChecksum(key, value)
 }
 
 func dbDeleteAsset(dbTx database.Tx, key []byte) error {
@@ -156,13 +193,568 @@ func dbHasAsset(dbTx database.Tx, key []byte) bool {
 	return assetBucket.KeyExists(key)
 }
 
+func dbPutOracle(dbTx database.Tx, key, value []byte) error {
+	oracleBucket := dbTx.Data().Bucket(dbnamespace.OracleBucket)
+
+	err := oracleBucket.Put(key, value)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to put oracle %v", key)
+		return NewSmartContractError(ErrPutDB, errString, err)
+	}
+	return nil
+}
+
+func dbFetchOracle(dbTx database.Tx, key []byte) ([]byte, error) {
+	oracleBucket := dbTx.Data().Bucket(dbnamespace.OracleBucket)
+
+	value := oracleBucket.Get(key)
+	if value == nil {
+		errString := fmt.Sprintf("Failed to find oracle %v", key)
+		return nil, NewSmartContractError(ErrNotFoundFormDB, errString, nil)
+	}
+
+	return value, nil
+}
+
+func dbDeleteOracle(dbTx database.Tx, key []byte) error {
+	oracleBucket := dbTx.Data().Bucket(dbnamespace.OracleBucket)
+
+	err := oracleBucket.Delete(key)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to delete oracle %v", key)
+		return NewSmartContractError(ErrDeleteDB, errString, err)
+	}
+
+	return nil
+}
+
+func dbHasOracle(dbTx database.Tx, key []byte) bool {
+	oracleBucket := dbTx.Data().Bucket(dbnamespace.OracleBucket)
+	return oracleBucket.KeyExists(key)
+}
+
+//dbForEachRecord calls fn with the key and the value without checksum of each record of bucket in key order
+//it is used on the contract, asset and asset contract buckets
+func dbForEachRecord(dbTx database.Tx, bucket []byte, fn func(k, v []byte) error) error {
+	return dbTx.Data().Bucket(bucket).ForEach(func(k, v []byte) error {
+		value, err := verifyChecksum(k, v)
+		if err != nil {
+			return err
+		}
+		return fn(k, value)
+	})
+}
+
+func dbForEachContract(dbTx database.Tx, fn func(k, v []byte) error) error {
+	return dbForEachRecord(dbTx, dbnamespace.ContractBucket, fn)
+}
+
+func dbForEachAsset(dbTx database.Tx, fn func(k, v []byte) error) error {
+	return dbForEachRecord(dbTx, dbnamespace.AssetBucket, fn)
+}
+
+func dbForEachAssetContract(dbTx database.Tx, fn func(k, v []byte) error) error {
+	return dbForEachRecord(dbTx, dbnamespace.AssetContractBucket, fn)
+}
+
+//dbForEachIntactContract calls fn with the address and the value without checksum of each contract in key order
+//contracts failing their checksum are skipped, they are found by CheckConsistency
+func dbForEachIntactContract(dbTx database.Tx, fn func(k, v []byte) error) error {
+	return dbTx.Data().Bucket(dbnamespace.ContractBucket).ForEach(func(k, v []byte) error {
+		value, err := verifyChecksum(k, v)
+		if err != nil {
+			return nil
+		}
+		return fn(k, value)
+	})
+}
+
+func dbForEachOracle(dbTx database.Tx, fn func(k, v []byte) error) error {
+	return dbTx.Data().Bucket(dbnamespace.OracleBucket).ForEach(fn)
+}
+
+//dbListKeysPaged returns at most limit keys of bucket from start and the key following them
+//a nil start means the first key and a nil next key means the end of bucket, a negative limit lists all keys
+func dbListKeysPaged(dbTx database.Tx, bucket []byte, start []byte, limit int) ([]hash.HashType, hash.HashType) {
+	cursor := dbTx.Data().Bucket(bucket).Cursor()
+	ok := cursor.First()
+	if start != nil {
+		ok = cursor.Seek(start)
+	}
+	keys := make([]hash.HashType, 0, 16)
+	for ; ok; ok = cursor.Next() {
+		if len(keys) == limit {
+			return keys, append([]byte(nil), cursor.Key()...)
+		}
+		keys = append(keys, append([]byte(nil), cursor.Key()...))
+	}
+	return keys, nil
+}
+
+func dbListContractsPaged(dbTx database.Tx, start []byte, limit int) ([]hash.HashType, hash.HashType) {
+	return dbListKeysPaged(dbTx, dbnamespace.ContractBucket, start, limit)
+}
+
+func dbListAssetsPaged(dbTx database.Tx, start []byte, limit int) ([]hash.HashType, hash.HashType) {
+	return dbListKeysPaged(dbTx, dbnamespace.AssetBucket, start, limit)
+}
+
+//dbListAssetContracts returns the contract definitions associated with asset
+func dbListAssetContracts(dbTx database.Tx, asset []byte) ([][]byte, error) {
+	assetContractBucket := dbTx.Data().Bucket(dbnamespace.AssetContractBucket)
+
+	values := make([][]byte, 0)
+	cursor := assetContractBucket.Cursor()
+	for ok := cursor.Seek(asset); ok && bytes.HasPrefix(cursor.Key(), asset); ok = cursor.Next() {
+		value, err := verifyChecksum(cursor.Key(), cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, append([]byte(nil), value...))
+	}
+	return values, nil
+}
+
+//The followings define the kinds of records in the MCI index
+//an index key is MCI(8) | kind(1) | record key
+const (
+	mciIndexContract byte = iota
+	mciIndexAsset
+	mciIndexAssetContract
+	mciIndexOracle
+)
+
+func mciIndexKey(mci uint64, kind byte, key []byte) []byte {
+	buf := make([]byte, 9, 9+len(key))
+	binary.BigEndian.PutUint64(buf, mci)
+	buf[8] = kind
+	return append(buf, key...)
+}
+
+func dbPutMCIIndex(dbTx database.Tx, mci uint64, kind byte, key []byte) error {
+	indexBucket := dbTx.Data().Bucket(dbnamespace.MCIIndexBucket)
+
+	err := indexBucket.Put(mciIndexKey(mci, kind, key), []byte{})
+	if err != nil {
+		errString := fmt.Sprintf("Failed to put mci index %v", key)
+		return NewSmartContractError(ErrPutDB, errString, err)
+	}
+	return nil
+}
+
+func dbDeleteMCIIndex(dbTx database.Tx, indexKey []byte) error {
+	indexBucket := dbTx.Data().Bucket(dbnamespace.MCIIndexBucket)
+
+	err := indexBucket.Delete(indexKey)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to delete mci index %v", indexKey)
+		return NewSmartContractError(ErrDeleteDB, errString, err)
+	}
+	return nil
+}
+
+//dbReindexMCI moves the index entry of a record overwritten at mci, old is the previous value of the record
+func dbReindexMCI(dbTx database.Tx, mci uint64, kind byte, key []byte, old []byte) error {
+	if len(old) >= 8 {
+		err := dbDeleteMCIIndex(dbTx, mciIndexKey(binary.BigEndian.Uint64(old), kind, key))
+		if err != nil {
+			return err
+		}
+	}
+	return dbPutMCIIndex(dbTx, mci, kind, key)
+}
+
+//dbFetchMCIIndexAbove returns the index keys of records stored above mci
+func dbFetchMCIIndexAbove(dbTx database.Tx, mci uint64) [][]byte {
+	indexBucket := dbTx.Data().Bucket(dbnamespace.MCIIndexBucket)
+
+	seek := make([]byte, 8)
+	binary.BigEndian.PutUint64(seek, mci+1)
+	keys := make([][]byte, 0)
+	cursor := indexBucket.Cursor()
+	for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+		keys = append(keys, append([]byte(nil), cursor.Key()...))
+	}
+	return keys
+}
+
+//The undo records keep the value an asset, an asset contract or an oracle had before it is overwritten at a higher MCI
+//an undo key is kind(1) | record key | MCI(8) the record is overwritten at, the kinds are the ones of the MCI index
+func undoKey(kind byte, key []byte, mci uint64) []byte {
+	buf := make([]byte, 9+len(key))
+	buf[0] = kind
+	copy(buf[1:], key)
+	binary.BigEndian.PutUint64(buf[1+len(key):], mci)
+	return buf
+}
+
+func dbPutUndo(dbTx database.Tx, kind byte, key []byte, mci uint64, value []byte) error {
+	undoBucket := dbTx.Data().Bucket(dbnamespace.UndoBucket)
+
+	k := undoKey(kind, key, mci)
+	err := undoBucket.Put(k, withChecksum(value))
+	if err != nil {
+		errString := fmt.Sprintf("Failed to put undo record %v", key)
+		return NewSmartContractError(ErrPutDB, errString, err)
+	}
+	return nil
+}
+
+//dbFetchUndo returns the value of the record before it was overwritten at mci, or nil if there is no intact undo record
+func dbFetchUndo(dbTx database.Tx, kind byte, key []byte, mci uint64) []byte {
+	undoBucket := dbTx.Data().Bucket(dbnamespace.UndoBucket)
+
+	k := undoKey(kind, key, mci)
+	value := undoBucket.Get(k)
+	if value == nil {
+		return nil
+	}
+	value, err := verifyChecksum(k, value)
+	if err != nil || len(value) < 8 {
+		return nil
+	}
+	return value
+}
+
+func dbHasUndo(dbTx database.Tx, kind byte, key []byte, mci uint64) bool {
+	undoBucket := dbTx.Data().Bucket(dbnamespace.UndoBucket)
+
+	k := undoKey(kind, key, mci)
+	return undoBucket.KeyExists(k)
+}
+
+func dbDeleteUndo(dbTx database.Tx, kind byte, key []byte, mci uint64) error {
+	undoBucket := dbTx.Data().Bucket(dbnamespace.UndoBucket)
+
+	k := undoKey(kind, key, mci)
+	err := undoBucket.Delete(k)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to delete undo record %v", key)
+		return NewSmartContractError(ErrDeleteDB, errString, err)
+	}
+	return nil
+}
+
+//dbDeleteUndoOf deletes the undo records of the record whatever their MCI
+func dbDeleteUndoOf(dbTx database.Tx, kind byte, key []byte) error {
+	undoBucket := dbTx.Data().Bucket(dbnamespace.UndoBucket)
+
+	prefix := undoKey(kind, key, 0)[:1+len(key)]
+	keys := make([][]byte, 0)
+	cursor := undoBucket.Cursor()
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(), prefix); ok = cursor.Next() {
+		if len(cursor.Key()) == len(prefix)+8 {
+			keys = append(keys, append([]byte(nil), cursor.Key()...))
+		}
+	}
+	for _, k := range keys {
+		err := undoBucket.Delete(k)
+		if err != nil {
+			errString := fmt.Sprintf("Failed to delete undo record %v", key)
+			return NewSmartContractError(ErrDeleteDB, errString, err)
+		}
+	}
+	return nil
+}
+
+//dbDeleteAssetContracts deletes all contract definitions associated with asset, their usage and MCI index
+func dbDeleteAssetContracts(dbTx database.Tx, asset []byte) error {
+	assetContractBucket := dbTx.Data().Bucket(dbnamespace.AssetContractBucket)
+
+	keys := make([][]byte, 0)
+	cursor := assetContractBucket.Cursor()
+	for ok := cursor.Seek(asset); ok && bytes.HasPrefix(cursor.Key(), asset); ok = cursor.Next() {
+		keys = append(keys, append([]byte(nil), cursor.Key()...))
+	}
+	for _, key := range keys {
+		old, _ := dbFetchAssetContract(dbTx, key)
+		err := dbDeleteAssetContract(dbTx, key)
+		if err != nil {
+			return err
+		}
+		err = dbDeleteUndoOf(dbTx, mciIndexAssetContract, key)
+		if err != nil {
+			return err
+		}
+		if len(old) >= 8 {
+			err = dbDeleteMCIIndex(dbTx, mciIndexKey(binary.BigEndian.Uint64(old), mciIndexAssetContract, key))
+		} else {
+			err = dbDeleteMCIIndexOf(dbTx, mciIndexAssetContract, key)
+		}
+		if err != nil {
+			return err
+		}
+		err = dbDeleteContractUsageIndex(dbTx, key[len(asset):], asset)
+		if err != nil {
+			return err
+		}
+		err = dbAddContractRef(dbTx, key[len(asset):], -1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//dbFetchAssetMCI returns the MCI asset is stored at, or 0 if the asset is not stored or corrupted
+func dbFetchAssetMCI(dbTx database.Tx, asset []byte) uint64 {
+	value, err := dbFetchAsset(dbTx, asset)
+	if err != nil || len(value) < 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+//dbFetchCount returns the count of contract address in count bucket, a missing count is 0
+func dbFetchCount(dbTx database.Tx, bucket, contract []byte) uint32 {
+	countBucket := dbTx.Data().Bucket(bucket)
+
+	value := countBucket.Get(contract)
+	if len(value) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(value)
+}
+
+//dbPutCount puts the count of contract address in count bucket, a zero count is deleted
+func dbPutCount(dbTx database.Tx, bucket, contract []byte, count uint32) error {
+	countBucket := dbTx.Data().Bucket(bucket)
+
+	if count == 0 {
+		err := countBucket.Delete(contract)
+		if err != nil {
+			errString := fmt.Sprintf("Failed to delete %s %v", bucket, contract)
+			return NewSmartContractError(ErrDeleteDB, errString, err)
+		}
+		return nil
+	}
+
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, count)
+	err := countBucket.Put(contract, buf)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to put %s %v", bucket, contract)
+		return NewSmartContractError(ErrPutDB, errString, err)
+	}
+	return nil
+}
+
+//dbAddCount adds delta to the count of contract address in count bucket, the count doesn't go below 0
+func dbAddCount(dbTx database.Tx, bucket, contract []byte, delta int) error {
+	count := int64(dbFetchCount(dbTx, bucket, contract)) + int64(delta)
+	if count < 0 {
+		count = 0
+	}
+	return dbPutCount(dbTx, bucket, contract, uint32(count))
+}
+
+//dbFetchContractRefCount returns the number of asset contract definitions and assets referring to contract address
+func dbFetchContractRefCount(dbTx database.Tx, contract []byte) uint32 {
+	return dbFetchCount(dbTx, dbnamespace.ContractRefCountBucket, contract)
+}
+
+func dbPutContractRefCount(dbTx database.Tx, contract []byte, count uint32) error {
+	return dbPutCount(dbTx, dbnamespace.ContractRefCountBucket, contract, count)
+}
+
+func dbAddContractRef(dbTx database.Tx, contract []byte, delta int) error {
+	return dbAddCount(dbTx, dbnamespace.ContractRefCountBucket, contract, delta)
+}
+
+//dbFetchContractRetainCount returns the number of retains of contract address from outside the library
+func dbFetchContractRetainCount(dbTx database.Tx, contract []byte) uint32 {
+	return dbFetchCount(dbTx, dbnamespace.ContractRetainBucket, contract)
+}
+
+func dbAddContractRetain(dbTx database.Tx, contract []byte, delta int) error {
+	return dbAddCount(dbTx, dbnamespace.ContractRetainBucket, contract, delta)
+}
+
+//dbPutIndex puts the entry prefix | key to index bucket
+func dbPutIndex(dbTx database.Tx, bucket, prefix, key []byte) error {
+	indexBucket := dbTx.Data().Bucket(bucket)
+
+	err := indexBucket.Put(append(append([]byte(nil), prefix...), key...), []byte{})
+	if err != nil {
+		errString := fmt.Sprintf("Failed to put %s %v", bucket, key)
+		return NewSmartContractError(ErrPutDB, errString, err)
+	}
+	return nil
+}
+
+//dbDeleteIndex deletes the entry prefix | key from index bucket
+func dbDeleteIndex(dbTx database.Tx, bucket, prefix, key []byte) error {
+	indexBucket := dbTx.Data().Bucket(bucket)
+
+	err := indexBucket.Delete(append(append([]byte(nil), prefix...), key...))
+	if err != nil {
+		errString := fmt.Sprintf("Failed to delete %s %v", bucket, key)
+		return NewSmartContractError(ErrDeleteDB, errString, err)
+	}
+	return nil
+}
+
+//dbListIndex returns the keys indexed under prefix in index bucket
+func dbListIndex(dbTx database.Tx, bucket, prefix []byte) []hash.HashType {
+	indexBucket := dbTx.Data().Bucket(bucket)
+
+	keys := make([]hash.HashType, 0)
+	cursor := indexBucket.Cursor()
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(), prefix); ok = cursor.Next() {
+		keys = append(keys, append([]byte(nil), cursor.Key()[len(prefix):]...))
+	}
+	return keys
+}
+
+func dbPutPublisherIndex(dbTx database.Tx, publisher, asset []byte) error {
+	return dbPutIndex(dbTx, dbnamespace.PublisherIndexBucket, publisher, asset)
+}
+
+func dbDeletePublisherIndex(dbTx database.Tx, publisher, asset []byte) error {
+	return dbDeleteIndex(dbTx, dbnamespace.PublisherIndexBucket, publisher, asset)
+}
+
+func dbListPublisherIndex(dbTx database.Tx, publisher []byte) []hash.HashType {
+	return dbListIndex(dbTx, dbnamespace.PublisherIndexBucket, publisher)
+}
+
+func dbPutContractUsageIndex(dbTx database.Tx, contract, asset []byte) error {
+	return dbPutIndex(dbTx, dbnamespace.ContractUsageIndexBucket, contract, asset)
+}
+
+func dbDeleteContractUsageIndex(dbTx database.Tx, contract, asset []byte) error {
+	return dbDeleteIndex(dbTx, dbnamespace.ContractUsageIndexBucket, contract, asset)
+}
+
+func dbListContractUsageIndex(dbTx database.Tx, contract []byte) []hash.HashType {
+	return dbListIndex(dbTx, dbnamespace.ContractUsageIndexBucket, contract)
+}
+
+//quarantineKey is the bucket name | 0x00 | record key
+func quarantineKey(bucket, key []byte) []byte {
+	buf := make([]byte, 0, len(bucket)+1+len(key))
+	buf = append(append(buf, bucket...), 0)
+	return append(buf, key...)
+}
+
+//dbQuarantine moves the raw record stored at key of bucket to the quarantine bucket
+//it returns the raw record, or nil if there is no record
+func dbQuarantine(dbTx database.Tx, bucket, key []byte) ([]byte, error) {
+	record := dbTx.Data().Bucket(bucket).Get(key)
+	if record == nil {
+		return nil, nil
+	}
+	record = append([]byte(nil), record...)
+
+	err := dbTx.Data().Bucket(dbnamespace.QuarantineBucket).Put(quarantineKey(bucket, key), record)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to quarantine %s %v", bucket, key)
+		return nil, NewSmartContractError(ErrPutDB, errString, err)
+	}
+	err = dbTx.Data().Bucket(bucket).Delete(key)
+	if err != nil {
+		errString := fmt.Sprintf("Failed to delete %s %v", bucket, key)
+		return nil, NewSmartContractError(ErrDeleteDB, errString, err)
+	}
+	return record, nil
+}
+
+func dbQuarantineContract(dbTx database.Tx, key []byte) ([]byte, error) {
+	return dbQuarantine(dbTx, dbnamespace.ContractBucket, key)
+}
+
+func dbQuarantineAsset(dbTx database.Tx, key []byte) ([]byte, error) {
+	return dbQuarantine(dbTx, dbnamespace.AssetBucket, key)
+}
+
+func dbQuarantineAssetContract(dbTx database.Tx, key []byte) ([]byte, error) {
+	return dbQuarantine(dbTx, dbnamespace.AssetContractBucket, key)
+}
+
+//dbListQuarantine returns the quarantined records
+func dbListQuarantine(dbTx database.Tx) ([]*QuarantinedRecord, error) {
+	records := make([]*QuarantinedRecord, 0)
+	err := dbTx.Data().Bucket(dbnamespace.QuarantineBucket).ForEach(func(k, v []byte) error {
+		i := bytes.IndexByte(k, 0)
+		if i < 0 {
+			return nil
+		}
+		records = append(records, &QuarantinedRecord{
+			Bucket: string(k[:i]),
+			Key:    append([]byte(nil), k[i+1:]...),
+			Value:  append([]byte(nil), v...),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, NewSmartContractError(ErrForEachDB, "Failed to list quarantined records", err)
+	}
+	return records, nil
+}
+
+//dbDeleteMCIIndexOf deletes the MCI index entries of the record whatever their MCI
+func dbDeleteMCIIndexOf(dbTx database.Tx, kind byte, key []byte) error {
+	indexBucket := dbTx.Data().Bucket(dbnamespace.MCIIndexBucket)
+
+	indexKeys := make([][]byte, 0)
+	err := indexBucket.ForEach(func(k, v []byte) error {
+		if len(k) == 9+len(key) && k[8] == kind && bytes.Equal(k[9:], key) {
+			indexKeys = append(indexKeys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return NewSmartContractError(ErrForEachDB, "Failed to list mci index", err)
+	}
+	for _, indexKey := range indexKeys {
+		err = dbDeleteMCIIndex(dbTx, indexKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//dbDeletePublisherIndexOf deletes the publisher index entries of asset whatever their publisher
+func dbDeletePublisherIndexOf(dbTx database.Tx, asset []byte) error {
+	indexBucket := dbTx.Data().Bucket(dbnamespace.PublisherIndexBucket)
+
+	keys := make([][]byte, 0)
+	err := indexBucket.ForEach(func(k, v []byte) error {
+		if len(k) > len(asset) && bytes.HasSuffix(k, asset) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return NewSmartContractError(ErrForEachDB, "Failed to list publisher index", err)
+	}
+	for _, key := range keys {
+		err = indexBucket.Delete(key)
+		if err != nil {
+			errString := fmt.Sprintf("Failed to delete publisher index %v", key)
+			return NewSmartContractError(ErrDeleteDB, errString, err)
+		}
+	}
+	return nil
+}
+
 //CreateSmartContractBucket  is the bucket associated with creating smart contracts
 func CreateSmartContractBucket(db database.Db) error {
 	err := db.Update(func(tx database.Tx) error {
-		errs := make([]error, 3)
+		errs := make([]error, 12)
 		_, errs[0] = tx.Data().CreateBucket(dbnamespace.ContractBucket)
 		_, errs[1] = tx.Data().CreateBucket(dbnamespace.AssetContractBucket)
 		_, errs[2] = tx.Data().CreateBucket(dbnamespace.AssetBucket)
+		_, errs[3] = tx.Data().CreateBucket(dbnamespace.OracleBucket)
+		_, errs[4] = tx.Data().CreateBucket(dbnamespace.MCIIndexBucket)
+		_, errs[5] = tx.Data().CreateBucket(dbnamespace.PublisherIndexBucket)
+		_, errs[6] = tx.Data().CreateBucket(dbnamespace.ContractUsageIndexBucket)
+		_, errs[7] = tx.Data().CreateBucket(dbnamespace.MetaBucket)
+		_, errs[8] = tx.Data().CreateBucket(dbnamespace.QuarantineBucket)
+		_, errs[9] = tx.Data().CreateBucket(dbnamespace.ContractRefCountBucket)
+		_, errs[10] = tx.Data().CreateBucket(dbnamespace.ContractRetainBucket)
+		_, errs[11] = tx.Data().CreateBucket(dbnamespace.UndoBucket)
 
 		for _, err := range errs {
 			if err != nil {
@@ -174,7 +766,50 @@ func CreateSmartContractBucket(db database.Db) error {
 			}
 		}
 
-		return nil
+		//a new database is created with the current storage version
+		if errs[0] == nil {
+			return dbPutStorageVersion(tx, StorageVersion)
+		}
+		return migrateStorage(tx)
 	})
 	return err
 }
+
+//dbFetchStorageVersion returns the storage version, databases created before versioning are version 0
+func dbFetchStorageVersion(dbTx database.Tx) uint32 {
+	metaBucket := dbTx.Data().Bucket(dbnamespace.MetaBucket)
+
+	value := metaBucket.Get(dbnamespace.StorageVersionKey)
+	if len(value) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(value)
+}
+
+func dbPutStorageVersion(dbTx database.Tx, version uint32) error {
+	metaBucket := dbTx.Data().Bucket(dbnamespace.MetaBucket)
+
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, version)
+	err := metaBucket.Put(dbnamespace.StorageVersionKey, buf)
+	if err != nil {
+		return NewSmartContractError(ErrPutDB, "Failed to put storage version", err)
+	}
+	return nil
+}
+
+//dbFetchGarbageCollection returns if the contract references of stored outputs are counted
+func dbFetchGarbageCollection(dbTx database.Tx) bool {
+	metaBucket := dbTx.Data().Bucket(dbnamespace.MetaBucket)
+	return metaBucket.KeyExists(dbnamespace.GarbageCollectionKey)
+}
+
+func dbPutGarbageCollection(dbTx database.Tx) error {
+	metaBucket := dbTx.Data().Bucket(dbnamespace.MetaBucket)
+
+	err := metaBucket.Put(dbnamespace.GarbageCollectionKey, []byte{1})
+	if err != nil {
+		return NewSmartContractError(ErrPutDB, "Failed to enable garbage collection", err)
+	}
+	return nil
+}
@@ -0,0 +1,911 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+	"github.com/pkg/errors"
+)
+
+//MemoryContractStore is a ContractStore kept in maps for tests and tools running without a database
+//each map stands for a bucket of ContractLibrary and holds the same records, checksums, indexes, undo records
+//and reference counts, so the methods of ContractStore give the same results on both stores
+//consistency check, snapshot and storage migration work on the database and exist only in ContractLibrary
+type MemoryContractStore struct {
+	readOnly bool
+	data     *memoryData
+}
+
+type memoryData struct {
+	sync.RWMutex
+	contracts      map[string][]byte
+	assets         map[string][]byte
+	assetContracts map[string][]byte
+	oracles        map[string][]byte
+	mciIndex       map[string][]byte
+	publisherIndex map[string][]byte
+	usageIndex     map[string][]byte
+	refCounts      map[string]uint32
+	retains        map[string]uint32
+	undo           map[string][]byte
+	quarantined    map[string]*QuarantinedRecord
+	gcEnabled      bool
+}
+
+//NewMemoryContractStore is to create an empty writable in-memory store
+func NewMemoryContractStore() *MemoryContractStore {
+	return &MemoryContractStore{
+		data: &memoryData{
+			contracts:      make(map[string][]byte),
+			assets:         make(map[string][]byte),
+			assetContracts: make(map[string][]byte),
+			oracles:        make(map[string][]byte),
+			mciIndex:       make(map[string][]byte),
+			publisherIndex: make(map[string][]byte),
+			usageIndex:     make(map[string][]byte),
+			refCounts:      make(map[string]uint32),
+			retains:        make(map[string]uint32),
+			undo:           make(map[string][]byte),
+			quarantined:    make(map[string]*QuarantinedRecord),
+		},
+	}
+}
+
+//ReadOnly returns a read-only view of the store, it shares the records of the store
+func (store *MemoryContractStore) ReadOnly() *MemoryContractStore {
+	return &MemoryContractStore{
+		readOnly: true,
+		data:     store.data,
+	}
+}
+
+//sortedKeys returns the keys of m in the order a database cursor visits them
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//prefixedKeys returns the keys of m starting with prefix in the order a database cursor visits them
+func prefixedKeys(m map[string][]byte, prefix []byte) []string {
+	keys := make([]string, 0)
+	for k := range m {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//pageKeys pages sorted keys like dbListKeysPaged
+func pageKeys(keys []string, start hash.HashType, limit int) ([]hash.HashType, hash.HashType) {
+	i := sort.SearchStrings(keys, string(start))
+	page := make([]hash.HashType, 0, 16)
+	for ; i < len(keys); i++ {
+		if len(page) == limit {
+			return page, hash.HashType(keys[i])
+		}
+		page = append(page, hash.HashType(keys[i]))
+	}
+	return page, nil
+}
+
+func notFoundError(kind string, key []byte) error {
+	errString := fmt.Sprintf("Failed to find %s %v", kind, key)
+	return NewSmartContractError(ErrNotFoundFormDB, errString, nil)
+}
+
+//fetch returns the record of kind stored at key of records without checksum, like the dbFetch functions
+func fetch(records map[string][]byte, kind string, key []byte) ([]byte, error) {
+	record, ok := records[string(key)]
+	if !ok {
+		return nil, notFoundError(kind, key)
+	}
+	return verifyChecksum(key, record)
+}
+
+//listIndex returns the keys indexed under prefix in index like dbListIndex
+func listIndex(index map[string][]byte, prefix []byte) []hash.HashType {
+	keys := make([]hash.HashType, 0)
+	for _, k := range prefixedKeys(index, prefix) {
+		keys = append(keys, hash.HashType(k[len(prefix):]))
+	}
+	return keys
+}
+
+//addRefCount adds delta to the reference count of contract like dbAddContractRef
+func (data *memoryData) addRefCount(contract []byte, delta int) {
+	count := int64(data.refCounts[string(contract)]) + int64(delta)
+	if count <= 0 {
+		delete(data.refCounts, string(contract))
+		return
+	}
+	data.refCounts[string(contract)] = uint32(count)
+}
+
+//reindexMCI moves the index entry of a record overwritten at mci like dbReindexMCI
+func (data *memoryData) reindexMCI(mci uint64, kind byte, key []byte, old []byte) {
+	if len(old) >= 8 {
+		delete(data.mciIndex, string(mciIndexKey(binary.BigEndian.Uint64(old), kind, key)))
+	}
+	data.mciIndex[string(mciIndexKey(mci, kind, key))] = []byte{}
+}
+
+//deleteMCIIndexOf deletes the MCI index entries of the record whatever their MCI
+func (data *memoryData) deleteMCIIndexOf(kind byte, key []byte) {
+	for k := range data.mciIndex {
+		if len(k) == 9+len(key) && k[8] == kind && k[9:] == string(key) {
+			delete(data.mciIndex, k)
+		}
+	}
+}
+
+//deletePublisherIndexOf deletes the publisher index entries of asset whatever their publisher
+func (data *memoryData) deletePublisherIndexOf(asset []byte) {
+	for k := range data.publisherIndex {
+		if len(k) > len(asset) && strings.HasSuffix(k, string(asset)) {
+			delete(data.publisherIndex, k)
+		}
+	}
+}
+
+//saveUndo keeps old, the value of the record of kind at key before it is overwritten at mci, like ContractLibrary
+func (data *memoryData) saveUndo(kind byte, key []byte, mci uint64, old []byte) {
+	k := string(undoKey(kind, key, mci))
+	if _, ok := data.undo[k]; len(old) < 8 || binary.BigEndian.Uint64(old) >= mci || ok {
+		return
+	}
+	data.undo[k] = withChecksum(old)
+}
+
+//fetchUndo returns the value of the record before it was overwritten at mci, or nil if there is no intact undo record
+func (data *memoryData) fetchUndo(kind byte, key []byte, mci uint64) []byte {
+	k := undoKey(kind, key, mci)
+	record, ok := data.undo[string(k)]
+	if !ok {
+		return nil
+	}
+	value, err := verifyChecksum(k, record)
+	if err != nil || len(value) < 8 {
+		return nil
+	}
+	return value
+}
+
+//deleteUndoOf deletes the undo records of the record whatever their MCI
+func (data *memoryData) deleteUndoOf(kind byte, key []byte) {
+	prefix := undoKey(kind, key, 0)[:1+len(key)]
+	for _, k := range prefixedKeys(data.undo, prefix) {
+		if len(k) == len(prefix)+8 {
+			delete(data.undo, k)
+		}
+	}
+}
+
+//SaveContract is used to store contract, a stored contract keeps the MCI it was first stored at
+func (store *MemoryContractStore) SaveContract(contract *structure.Contract, mci uint64) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+
+	address := contract.CalcAddress()
+	buf = append(buf, contract.Serialize()...)
+
+	store.data.Lock()
+	defer store.data.Unlock()
+	old, _ := fetch(store.data.contracts, "contract", address)
+	if len(old) >= 8 {
+		return nil
+	}
+	store.data.contracts[string(address)] = withChecksum(buf)
+	store.data.mciIndex[string(mciIndexKey(mci, mciIndexContract, address))] = []byte{}
+	return nil
+}
+
+//ListContracts list contracts according to hashes
+func (store *MemoryContractStore) ListContracts() ([]hash.HashType, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	contracts, _ := pageKeys(sortedKeys(store.data.contracts), nil, -1)
+	return contracts, nil
+}
+
+//ListContractsPaged lists at most limit contract addresses from cursor in address order
+func (store *MemoryContractStore) ListContractsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error) {
+	if limit <= 0 {
+		return nil, nil, errors.Errorf("invalid page limit %d", limit)
+	}
+	store.data.RLock()
+	defer store.data.RUnlock()
+	contracts, next := pageKeys(sortedKeys(store.data.contracts), cursor, limit)
+	return contracts, next, nil
+}
+
+//LoadContract is to read contract according to the address
+func (store *MemoryContractStore) LoadContract(address hash.HashType) (*structure.Contract, uint64, error) {
+	store.data.RLock()
+	data, err := fetch(store.data.contracts, "contract", address)
+	store.data.RUnlock()
+	if err != nil {
+		return nil, math.MaxUint64, err
+	}
+	if len(data) < 8 {
+		return nil, math.MaxUint64, corruptRecordError(address, nil)
+	}
+	mci := binary.BigEndian.Uint64(data)
+
+	contract := structure.NewContract()
+	err = contract.Deserialize(data[8:])
+	if err != nil {
+		return nil, math.MaxUint64, corruptRecordError(address, err)
+	}
+
+	return contract, mci, nil
+}
+
+//HasContract returns if the contract is existed
+func (store *MemoryContractStore) HasContract(address hash.HashType) bool {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	_, ok := store.data.contracts[string(address)]
+	return ok
+}
+
+//RemoveContract is to remove contract
+func (store *MemoryContractStore) RemoveContract(address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.removeContract(address)
+	return nil
+}
+
+func (data *memoryData) removeContract(address []byte) {
+	old, _ := fetch(data.contracts, "contract", address)
+	delete(data.contracts, string(address))
+	if len(old) >= 8 {
+		delete(data.mciIndex, string(mciIndexKey(binary.BigEndian.Uint64(old), mciIndexContract, address)))
+	}
+}
+
+//SaveAssetContractDef is to store all contracts definition associated with asset
+//the definition is stored at the MCI of the asset, or 0 if the asset is not stored
+func (store *MemoryContractStore) SaveAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef) error {
+	var mci uint64
+	store.data.RLock()
+	if data, _ := fetch(store.data.assets, "asset", asset); len(data) >= 8 {
+		mci = binary.BigEndian.Uint64(data)
+	}
+	store.data.RUnlock()
+	return store.SaveAssetContractDefAt(asset, contractDef, mci)
+}
+
+//SaveAssetContractDefAt is to store contract definition associated with asset at mci
+//the definition is indexed by the last MCI it is stored at
+func (store *MemoryContractStore) SaveAssetContractDefAt(asset hash.HashType, contractDef *structure.ContractDef, mci uint64) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.putAssetContractDef(asset, contractDef, mci, true)
+	return nil
+}
+
+//putAssetContractDef stores contract definition associated with asset at mci
+//the value it overwrites is kept in an undo record if undo is set
+func (data *memoryData) putAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef, mci uint64, undo bool) {
+	addr := contractDef.Address
+	key := append(append([]byte(nil), asset...), addr...)
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+	buf = append(buf, contractDef.Serialize()...)
+
+	_, existed := data.assetContracts[string(key)]
+	old, _ := fetch(data.assetContracts, "assetContract", key)
+	if undo {
+		data.saveUndo(mciIndexAssetContract, key, mci, old)
+	}
+	data.assetContracts[string(key)] = withChecksum(buf)
+
+	data.reindexMCI(mci, mciIndexAssetContract, key, old)
+	data.usageIndex[string(addr)+string(asset)] = []byte{}
+	if !existed {
+		data.addRefCount(addr, 1)
+	}
+}
+
+//LoadAssetContractDef is to read contract definition associate with asset
+func (store *MemoryContractStore) LoadAssetContractDef(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, error) {
+	key := append(append([]byte(nil), asset...), addr...)
+
+	store.data.RLock()
+	data, err := fetch(store.data.assetContracts, "assetContract", key)
+	store.data.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, corruptRecordError(key, nil)
+	}
+
+	contractDef := new(structure.ContractDef)
+	err = contractDef.Deserialize(data[8:])
+	if err != nil {
+		return nil, corruptRecordError(key, err)
+	}
+
+	return contractDef, nil
+}
+
+//ListAssetContractDefs lists contract definitions associated with asset
+func (store *MemoryContractStore) ListAssetContractDefs(asset hash.HashType) ([]*structure.ContractDef, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	keys := prefixedKeys(store.data.assetContracts, asset)
+	contractDefs := make([]*structure.ContractDef, 0, len(keys))
+	for _, key := range keys {
+		value, err := verifyChecksum([]byte(key), store.data.assetContracts[key])
+		if err != nil {
+			return nil, err
+		}
+		if len(value) < 8 {
+			return nil, corruptRecordError(asset, nil)
+		}
+		contractDef := new(structure.ContractDef)
+		err = contractDef.Deserialize(value[8:])
+		if err != nil {
+			return nil, corruptRecordError(asset, err)
+		}
+		contractDefs = append(contractDefs, contractDef)
+	}
+	return contractDefs, nil
+}
+
+//RemoveAssetContract is to remove a contract associate with asset
+func (store *MemoryContractStore) RemoveAssetContract(asset hash.HashType, address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.removeAssetContract(asset, address)
+	return nil
+}
+
+func (data *memoryData) removeAssetContract(asset, address []byte) {
+	key := append(append([]byte(nil), asset...), address...)
+
+	_, existed := data.assetContracts[string(key)]
+	old, _ := fetch(data.assetContracts, "assetContract", key)
+	delete(data.assetContracts, string(key))
+	data.deleteUndoOf(mciIndexAssetContract, key)
+
+	delete(data.usageIndex, string(address)+string(asset))
+	if !existed {
+		return
+	}
+	if len(old) >= 8 {
+		delete(data.mciIndex, string(mciIndexKey(binary.BigEndian.Uint64(old), mciIndexAssetContract, key)))
+	} else {
+		data.deleteMCIIndexOf(mciIndexAssetContract, key)
+	}
+	data.addRefCount(address, -1)
+}
+
+//LoadAssetContract is to read a contract associate with current asset
+func (store *MemoryContractStore) LoadAssetContract(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error) {
+	return loadAssetContract(store, asset, addr)
+}
+
+//HasAssetContract is to check if current asset contract is exist
+func (store *MemoryContractStore) HasAssetContract(asset hash.HashType, addr hash.HashType) bool {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	_, ok := store.data.assetContracts[string(asset)+string(addr)]
+	return ok
+}
+
+//SaveAsset is to store asset
+func (store *MemoryContractStore) SaveAsset(unithash hash.HashType, asset *structure.IssueMessage, mci uint64) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.putAsset(unithash, asset, mci, true)
+	return nil
+}
+
+//putAsset stores asset at mci, the value it overwrites is kept in an undo record if undo is set
+func (data *memoryData) putAsset(address hash.HashType, asset *structure.IssueMessage, mci uint64, undo bool) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+	buf = append(buf, asset.Serialize()...)
+
+	//the indexes of the stored asset are removed before it is overwritten
+	var old []byte
+	if _, ok := data.assets[string(address)]; ok {
+		old, _ = fetch(data.assets, "asset", address)
+		data.unindexAsset(address, old)
+	}
+	if undo {
+		data.saveUndo(mciIndexAsset, address, mci, old)
+	}
+	data.assets[string(address)] = withChecksum(buf)
+
+	data.publisherIndex[string(asset.PublisherAddress)+string(address)] = []byte{}
+	for _, contractDef := range asset.Contracts {
+		data.addRefCount(contractDef.Address, 1)
+	}
+	data.mciIndex[string(mciIndexKey(mci, mciIndexAsset, address))] = []byte{}
+}
+
+//unindexAsset removes the publisher and MCI index entries of a stored asset and releases the contracts it refers to
+//old is the stored value of asset, if it can't be decoded the index entries of asset are searched by key
+func (data *memoryData) unindexAsset(asset []byte, old []byte) {
+	issueMessage := structure.NewIssueMessage()
+	if len(old) < 8 || issueMessage.Deserialize(old[8:]) != nil {
+		data.deletePublisherIndexOf(asset)
+		data.deleteMCIIndexOf(mciIndexAsset, asset)
+		return
+	}
+
+	delete(data.mciIndex, string(mciIndexKey(binary.BigEndian.Uint64(old), mciIndexAsset, asset)))
+	for _, contractDef := range issueMessage.Contracts {
+		data.addRefCount(contractDef.Address, -1)
+	}
+	delete(data.publisherIndex, string(issueMessage.PublisherAddress)+string(asset))
+}
+
+//LoadAsset is to read asset
+func (store *MemoryContractStore) LoadAsset(assetHash hash.HashType) (*structure.IssueMessage, uint64, error) {
+	store.data.RLock()
+	data, err := fetch(store.data.assets, "asset", assetHash)
+	store.data.RUnlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < 8 {
+		return nil, 0, corruptRecordError(assetHash, nil)
+	}
+	mci := binary.BigEndian.Uint64(data)
+
+	issueMessage := structure.NewIssueMessage()
+	err = issueMessage.Deserialize(data[8:])
+	if err != nil {
+		return nil, 0, corruptRecordError(assetHash, err)
+	}
+
+	return issueMessage, mci, nil
+}
+
+//HasAsset is to check if current asset is exist
+func (store *MemoryContractStore) HasAsset(asset hash.HashType) bool {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	_, ok := store.data.assets[string(asset)]
+	return ok
+}
+
+//RemoveAsset is to remove asset with the contract definitions associated with it
+func (store *MemoryContractStore) RemoveAsset(asset hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.removeAsset(asset)
+	return nil
+}
+
+func (data *memoryData) removeAsset(asset []byte) {
+	if _, ok := data.assets[string(asset)]; !ok {
+		return
+	}
+
+	old, _ := fetch(data.assets, "asset", asset)
+	data.unindexAsset(asset, old)
+	delete(data.assets, string(asset))
+	data.deleteUndoOf(mciIndexAsset, asset)
+
+	for _, key := range prefixedKeys(data.assetContracts, asset) {
+		data.removeAssetContract(asset, []byte(key[len(asset):]))
+	}
+}
+
+//ListAssets lists hashes of all assets
+func (store *MemoryContractStore) ListAssets() ([]hash.HashType, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	assets, _ := pageKeys(sortedKeys(store.data.assets), nil, -1)
+	return assets, nil
+}
+
+//ListAssetsPaged lists at most limit asset hashes from cursor, it pages like ListContractsPaged
+func (store *MemoryContractStore) ListAssetsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error) {
+	if limit <= 0 {
+		return nil, nil, errors.Errorf("invalid page limit %d", limit)
+	}
+	store.data.RLock()
+	defer store.data.RUnlock()
+	assets, next := pageKeys(sortedKeys(store.data.assets), cursor, limit)
+	return assets, next, nil
+}
+
+//ListAssetsByPublisher lists hashes of assets issued by publisher address
+func (store *MemoryContractStore) ListAssetsByPublisher(publisher hash.HashType) ([]hash.HashType, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	return listIndex(store.data.publisherIndex, publisher), nil
+}
+
+//ListAssetsByContract lists hashes of assets associated with the contract address
+func (store *MemoryContractStore) ListAssetsByContract(contract hash.HashType) ([]hash.HashType, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	return listIndex(store.data.usageIndex, contract), nil
+}
+
+//RollbackTo removes contracts, assets, contract definitions and oracles stored above mci, with the contract definitions of removed assets
+//a contract keeps the MCI it was first stored at, an asset, a definition or an oracle overwritten above mci is restored to the value it had at mci
+func (store *MemoryContractStore) RollbackTo(mci uint64) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	if mci == math.MaxUint64 {
+		return nil
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+
+	for _, indexKey := range sortedKeys(store.data.mciIndex) {
+		if binary.BigEndian.Uint64([]byte(indexKey)) <= mci {
+			continue
+		}
+		key := []byte(indexKey[9:])
+		switch indexKey[8] {
+		case mciIndexContract:
+			delete(store.data.contracts, string(key))
+		default:
+			store.data.rollbackRecord(indexKey[8], key, mci)
+		}
+		delete(store.data.mciIndex, indexKey)
+	}
+	return nil
+}
+
+//rollbackRecord restores the asset, asset contract or oracle at key to the value it had at mci by its undo records
+//the record is removed if it has no value at mci, or if it can't be decoded
+func (data *memoryData) rollbackRecord(kind byte, key []byte, mci uint64) {
+	//an asset contract key is the asset hash followed by the contract address of the same size
+	half := len(key) / 2
+	remove := func() {
+		switch kind {
+		case mciIndexAsset:
+			data.removeAsset(key)
+		case mciIndexAssetContract:
+			data.removeAssetContract(key[:half], key[half:])
+		default:
+			data.removeOracle(key)
+		}
+	}
+
+	for {
+		var value []byte
+		switch kind {
+		case mciIndexAsset:
+			value, _ = fetch(data.assets, "asset", key)
+		case mciIndexAssetContract:
+			value, _ = fetch(data.assetContracts, "assetContract", key)
+		case mciIndexOracle:
+			value = data.oracles[string(key)]
+		}
+		if len(value) >= 8 && binary.BigEndian.Uint64(value) <= mci {
+			return
+		}
+
+		var prev []byte
+		if len(value) >= 8 {
+			prev = data.fetchUndo(kind, key, binary.BigEndian.Uint64(value))
+		}
+		if prev == nil {
+			remove()
+			return
+		}
+		delete(data.undo, string(undoKey(kind, key, binary.BigEndian.Uint64(value))))
+
+		prevMCI := binary.BigEndian.Uint64(prev)
+		switch kind {
+		case mciIndexAsset:
+			issueMessage := structure.NewIssueMessage()
+			if issueMessage.Deserialize(prev[8:]) != nil {
+				remove()
+				return
+			}
+			data.putAsset(key, issueMessage, prevMCI, false)
+		case mciIndexAssetContract:
+			contractDef := new(structure.ContractDef)
+			if contractDef.Deserialize(prev[8:]) != nil {
+				remove()
+				return
+			}
+			data.putAssetContractDef(key[:half], contractDef, prevMCI, false)
+		default:
+			oracle := new(Oracle)
+			if oracle.Deserialize(prev[8:]) != nil {
+				remove()
+				return
+			}
+			data.putOracle(oracle, prevMCI, false)
+		}
+	}
+}
+
+//SaveOracle is to store oracle identity registered at mci, the oracle is keyed by its ID
+func (store *MemoryContractStore) SaveOracle(oracle *Oracle, mci uint64) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.putOracle(oracle, mci, true)
+	return nil
+}
+
+//putOracle stores oracle at mci, the value it overwrites is kept in an undo record if undo is set
+func (data *memoryData) putOracle(oracle *Oracle, mci uint64, undo bool) {
+	id := oracle.ID()
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, mci)
+	buf = append(buf, oracle.Serialize()...)
+
+	old := data.oracles[string(id)]
+	if undo {
+		data.saveUndo(mciIndexOracle, id, mci, old)
+	}
+	data.oracles[string(id)] = buf
+	data.reindexMCI(mci, mciIndexOracle, id, old)
+}
+
+//LoadOracle is to read oracle identity
+func (store *MemoryContractStore) LoadOracle(id hash.HashType) (*Oracle, error) {
+	store.data.RLock()
+	data, ok := store.data.oracles[string(id)]
+	store.data.RUnlock()
+	if !ok {
+		return nil, notFoundError("oracle", id)
+	}
+
+	if len(data) < 8 {
+		return nil, corruptRecordError(id, nil)
+	}
+	oracle := new(Oracle)
+	err := oracle.Deserialize(data[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	return oracle, nil
+}
+
+//FetchOracle returns the public key with scheme header of oracle, it returns nil if the oracle doesn't exist
+func (store *MemoryContractStore) FetchOracle(id hash.HashType) []byte {
+	return fetchOracle(store, id)
+}
+
+//HasOracle is to check if the oracle is exist
+func (store *MemoryContractStore) HasOracle(id hash.HashType) bool {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	_, ok := store.data.oracles[string(id)]
+	return ok
+}
+
+//RemoveOracle is to remove oracle identity and its MCI index
+func (store *MemoryContractStore) RemoveOracle(id hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.removeOracle(id)
+	return nil
+}
+
+func (data *memoryData) removeOracle(id []byte) {
+	old, ok := data.oracles[string(id)]
+	if !ok {
+		return
+	}
+	delete(data.oracles, string(id))
+	data.deleteUndoOf(mciIndexOracle, id)
+	if len(old) < 8 {
+		data.deleteMCIIndexOf(mciIndexOracle, id)
+		return
+	}
+	delete(data.mciIndex, string(mciIndexKey(binary.BigEndian.Uint64(old), mciIndexOracle, id)))
+}
+
+//quarantine moves the raw record stored at key of records to the quarantine like dbQuarantine
+//it returns false if there is no record
+func (data *memoryData) quarantine(bucket []byte, key []byte, records map[string][]byte) bool {
+	record, ok := records[string(key)]
+	if !ok {
+		return false
+	}
+	delete(records, string(key))
+	data.quarantined[string(quarantineKey(bucket, key))] = &QuarantinedRecord{
+		Bucket: string(bucket),
+		Key:    append([]byte(nil), key...),
+		Value:  record,
+	}
+	return true
+}
+
+//QuarantineContract moves a corrupted contract to the quarantine, so it is no longer loaded
+func (store *MemoryContractStore) QuarantineContract(address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	if store.data.quarantine(dbnamespace.ContractBucket, address, store.data.contracts) {
+		store.data.deleteMCIIndexOf(mciIndexContract, address)
+	}
+	return nil
+}
+
+//QuarantineAsset moves a corrupted asset to the quarantine, the contract definitions of asset are kept
+func (store *MemoryContractStore) QuarantineAsset(asset hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	if store.data.quarantine(dbnamespace.AssetBucket, asset, store.data.assets) {
+		store.data.deletePublisherIndexOf(asset)
+		store.data.deleteUndoOf(mciIndexAsset, asset)
+		store.data.deleteMCIIndexOf(mciIndexAsset, asset)
+	}
+	return nil
+}
+
+//QuarantineAssetContract moves a corrupted contract definition associated with asset to the quarantine
+func (store *MemoryContractStore) QuarantineAssetContract(asset hash.HashType, address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	key := append(append([]byte(nil), asset...), address...)
+	if store.data.quarantine(dbnamespace.AssetContractBucket, key, store.data.assetContracts) {
+		delete(store.data.usageIndex, string(address)+string(asset))
+		store.data.deleteMCIIndexOf(mciIndexAssetContract, key)
+		store.data.deleteUndoOf(mciIndexAssetContract, key)
+		store.data.addRefCount(address, -1)
+	}
+	return nil
+}
+
+//ListQuarantined lists the quarantined records
+func (store *MemoryContractStore) ListQuarantined() ([]*QuarantinedRecord, error) {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	keys := make([]string, 0, len(store.data.quarantined))
+	for k := range store.data.quarantined {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	records := make([]*QuarantinedRecord, 0, len(keys))
+	for _, k := range keys {
+		records = append(records, store.data.quarantined[k])
+	}
+	return records, nil
+}
+
+//ContractRefCount returns the number of stored asset contract definitions, assets and retains referring to address
+func (store *MemoryContractStore) ContractRefCount(address hash.HashType) uint32 {
+	store.data.RLock()
+	defer store.data.RUnlock()
+	return store.data.refCounts[string(address)] + store.data.retains[string(address)]
+}
+
+//RetainContract adds a reference to the contract from outside the store, such as a restrict of an output
+func (store *MemoryContractStore) RetainContract(address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.retains[string(address)]++
+	return nil
+}
+
+//ReleaseContract removes a reference added by RetainContract, a contract which is not retained is refused
+func (store *MemoryContractStore) ReleaseContract(address hash.HashType) error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	if store.data.retains[string(address)] == 0 {
+		return errors.Errorf("contract %v is not retained", address)
+	}
+	store.data.retains[string(address)]--
+	if store.data.retains[string(address)] == 0 {
+		delete(store.data.retains, string(address))
+	}
+	return nil
+}
+
+//EnableGarbageCollection records that the contracts of every stored output are retained by RetainContract
+func (store *MemoryContractStore) EnableGarbageCollection() error {
+	if store.readOnly {
+		return errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	store.data.gcEnabled = true
+	return nil
+}
+
+//CollectGarbage removes the unreferenced contracts stored before beforeMCI and returns their addresses
+//it is refused until EnableGarbageCollection is called, corrupted contracts are skipped
+func (store *MemoryContractStore) CollectGarbage(beforeMCI uint64) ([]hash.HashType, error) {
+	if store.readOnly {
+		return nil, errors.Errorf(writePermissionsError)
+	}
+	store.data.Lock()
+	defer store.data.Unlock()
+	if !store.data.gcEnabled {
+		return nil, NewSmartContractError(ErrGCDisabled, "Contract references of outputs are not counted", nil)
+	}
+
+	garbage := make([]hash.HashType, 0)
+	for _, address := range sortedKeys(store.data.contracts) {
+		value, err := verifyChecksum([]byte(address), store.data.contracts[address])
+		if err != nil || len(value) < 8 || binary.BigEndian.Uint64(value) >= beforeMCI {
+			continue
+		}
+		if store.data.refCounts[address]+store.data.retains[address] == 0 {
+			garbage = append(garbage, hash.HashType(address))
+		}
+	}
+
+	for _, address := range garbage {
+		store.data.removeContract(address)
+	}
+	return garbage, nil
+}
@@ -0,0 +1,258 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/infrastructure/database"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+)
+
+//testContractStore runs the same operations on a store and checks the results, view is a read-only view of store
+//put writes a raw record to a bucket of store, it is used to corrupt records
+func testContractStore(t *testing.T, store ContractStore, view ContractStore, put func(bucket, key, record []byte)) {
+	contract0, contract1 := CreateContract0(), CreateContract1()
+	address0, address1 := contract0.CalcAddress(), contract1.CalcAddress()
+	asset0, asset1 := FakeRandomHash(), FakeRandomHash()
+	msg0, msg1 := FakeIssueMessage(), FakeIssueMessage()
+	def0, def1 := CreateRandomContractDef(address0), CreateRandomContractDef(address1)
+
+	store.SaveContract(contract0, 1)
+	store.SaveContract(contract1, 2)
+	store.SaveAsset(asset0, msg0, 1)
+	store.SaveAsset(asset1, msg1, 2)
+	store.SaveAssetContractDefAt(asset0, def0, 1)
+	store.SaveAssetContractDefAt(asset1, def0, 2)
+	store.SaveAssetContractDefAt(asset1, def1, 2)
+
+	contract, mci, err := view.LoadContract(address0)
+	if err != nil || mci != 1 || !reflect.DeepEqual(contract.Serialize(), contract0.Serialize()) {
+		t.Errorf("Load contract error, %v", err)
+	}
+	_, mci, err = view.LoadAsset(asset1)
+	if err != nil || mci != 2 {
+		t.Errorf("Load asset error, %v", err)
+	}
+	def, contract, err := view.LoadAssetContract(asset1, address1)
+	if err != nil || !reflect.DeepEqual(def.Serialize(), def1.Serialize()) || contract == nil {
+		t.Errorf("Load asset contract error, %v", err)
+	}
+	_, _, err = view.LoadContract(FakeRandomHash())
+	if err == nil || !IsNotFoundFormDBError(err.(*SmartContractError)) {
+		t.Errorf("Missing contract should not be found, %v", err)
+	}
+
+	contracts, next, _ := view.ListContractsPaged(nil, 1)
+	rest, end, _ := view.ListContractsPaged(next, 1)
+	if len(contracts) != 1 || len(rest) != 1 || end != nil {
+		t.Error("List contracts paged error.")
+	}
+	defs, _ := view.ListAssetContractDefs(asset1)
+	if len(defs) != 2 {
+		t.Errorf("%d contract defs of asset1, expect 2.", len(defs))
+	}
+	assets, _ := view.ListAssetsByPublisher(msg0.PublisherAddress)
+	if len(assets) != 1 || !reflect.DeepEqual(assets[0], asset0) {
+		t.Error("List assets by publisher error.")
+	}
+	assets, _ = view.ListAssetsByContract(address0)
+	if len(assets) != 2 {
+		t.Errorf("%d assets use contract0, expect 2.", len(assets))
+	}
+
+	//the view refuses writes
+	if view.SaveContract(contract0, 3) == nil || view.RemoveAsset(asset0) == nil || view.RollbackTo(0) == nil {
+		t.Error("Read-only store should refuse writes.")
+	}
+
+	oracle := &Oracle{Scheme: 1, PubKey: FakeRandomHash(), Description: "price feed"}
+	store.SaveOracle(oracle, 3)
+	if !reflect.DeepEqual(view.FetchOracle(oracle.ID()), oracle.PublicKey()) {
+		t.Error("Fetch oracle error.")
+	}
+
+	store.QuarantineAssetContract(asset0, address0)
+	records, _ := view.ListQuarantined()
+	if len(records) != 1 || view.HasAssetContract(asset0, address0) {
+		t.Error("Quarantine asset contract error.")
+	}
+
+	//contract0 stored again keeps mci 1, the definition of asset0 stored at mci 2 is rolled back
+	store.SaveContract(contract0, 2)
+	store.SaveAssetContractDefAt(asset0, def1, 2)
+	store.RollbackTo(1)
+	if view.HasContract(address1) || view.HasAsset(asset1) || view.HasAssetContract(asset1, address1) ||
+		view.HasAssetContract(asset0, address1) || view.HasOracle(oracle.ID()) {
+		t.Error("Records above mci 1 should be rolled back.")
+	}
+	if !view.HasContract(address0) || !view.HasAsset(asset0) {
+		t.Error("Records at mci 1 should be kept.")
+	}
+	assets, _ = view.ListAssets()
+	if len(assets) != 1 {
+		t.Errorf("%d assets after rollback, expect 1.", len(assets))
+	}
+
+	//asset0, its definition of contract1 and the oracle overwritten above mci 1 are restored to their values at mci 1
+	store.SaveAssetContractDefAt(asset0, def1, 1)
+	store.SaveOracle(oracle, 1)
+	store.SaveAsset(asset0, msg1, 2)
+	store.SaveAsset(asset0, msg1, 3)
+	store.SaveAssetContractDefAt(asset0, CreateRandomContractDef(address1), 3)
+	store.SaveOracle(&Oracle{Scheme: 1, PubKey: oracle.PubKey, Description: "new feed"}, 3)
+	store.RollbackTo(1)
+	msg, mci, err := view.LoadAsset(asset0)
+	if err != nil || mci != 1 || !reflect.DeepEqual(msg.Serialize(), msg0.Serialize()) {
+		t.Errorf("Asset0 should be restored at mci 1, %v", err)
+	}
+	assets, _ = view.ListAssetsByPublisher(msg0.PublisherAddress)
+	if len(assets) != 1 || !reflect.DeepEqual(assets[0], asset0) {
+		t.Error("Restored asset0 should be listed by its publisher.")
+	}
+	def, err = view.LoadAssetContractDef(asset0, address1)
+	if err != nil || !reflect.DeepEqual(def.Serialize(), def1.Serialize()) {
+		t.Errorf("Definition of asset0 should be restored, %v", err)
+	}
+	restored, err := view.LoadOracle(oracle.ID())
+	if err != nil || restored.Description != oracle.Description {
+		t.Errorf("Oracle should be restored, %v", err)
+	}
+	store.RemoveAssetContract(asset0, address1)
+	store.RemoveOracle(oracle.ID())
+
+	//the definition of asset0 is quarantined, contract0 is only retained by an output and contract1 is unreferenced
+	store.SaveContract(contract1, 1)
+	if _, err := store.CollectGarbage(2); err == nil {
+		t.Error("Garbage collection should be refused before it is enabled.")
+	}
+	store.EnableGarbageCollection()
+	store.RetainContract(address0)
+	if view.ContractRefCount(address0) != 1 || view.ContractRefCount(address1) != 0 {
+		t.Errorf("Contract0 has %d references, expect 1.", view.ContractRefCount(address0))
+	}
+	removed, err := store.CollectGarbage(2)
+	if err != nil || !reflect.DeepEqual(removed, []hash.HashType{address1}) || view.HasContract(address1) {
+		t.Errorf("Unreferenced contract1 should be collected, %v", err)
+	}
+	store.ReleaseContract(address0)
+	if store.ReleaseContract(address0) == nil {
+		t.Error("Contract which is not retained should not be released.")
+	}
+	if view.ContractRefCount(address0) != 0 {
+		t.Errorf("Contract0 has %d references after release, expect 0.", view.ContractRefCount(address0))
+	}
+
+	//corrupted records are reported and quarantined with their raw records, a corrupted contract is not collected
+	asset2 := FakeRandomHash()
+	store.SaveAsset(asset2, msg0, 1)
+	store.SaveAssetContractDefAt(asset2, def0, 1)
+	put(dbnamespace.AssetContractBucket, append(append([]byte(nil), asset2...), address0...), withChecksum([]byte{1, 2, 3}))
+	_, err = view.LoadAssetContractDef(asset2, address0)
+	if err == nil || !IsCorruptRecordError(err.(*SmartContractError)) {
+		t.Errorf("Truncated contract def should fail to load, %v", err)
+	}
+	if _, err = view.ListAssetContractDefs(asset2); err == nil {
+		t.Error("Truncated contract def should fail to list.")
+	}
+	store.QuarantineAssetContract(asset2, address0)
+	if view.HasAssetContract(asset2, address0) || view.ContractRefCount(address0) != 0 {
+		t.Error("Quarantined contract def should be removed with its reference.")
+	}
+
+	record := withChecksum(append(make([]byte, 8), contract0.Serialize()...))
+	record[10] ^= 0xff
+	put(dbnamespace.ContractBucket, address0, record)
+	_, _, err = view.LoadContract(address0)
+	if err == nil || !IsCorruptRecordError(err.(*SmartContractError)) {
+		t.Errorf("Corrupted contract should fail to load, %v", err)
+	}
+	removed, err = store.CollectGarbage(math.MaxUint64)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("Corrupted contract should not be collected, %v", err)
+	}
+	store.QuarantineContract(address0)
+	if view.HasContract(address0) {
+		t.Error("Quarantined contract should be removed.")
+	}
+	assets, _ = view.ListAssetsByContract(address0)
+	for _, asset := range assets {
+		if reflect.DeepEqual(asset, asset2) {
+			t.Error("Quarantined contract def should not be indexed.")
+		}
+	}
+	records, _ = view.ListQuarantined()
+	if len(records) != 3 {
+		t.Fatalf("%d quarantined records, expect 3.", len(records))
+	}
+	for _, r := range records {
+		if r.Bucket == string(dbnamespace.ContractBucket) && !reflect.DeepEqual(r.Value, record) {
+			t.Error("Quarantined contract should keep its raw record.")
+		}
+	}
+}
+
+//memoryBucket returns the map of data standing for bucket
+func memoryBucket(data *memoryData, bucket []byte) map[string][]byte {
+	switch string(bucket) {
+	case string(dbnamespace.ContractBucket):
+		return data.contracts
+	case string(dbnamespace.AssetBucket):
+		return data.assets
+	case string(dbnamespace.AssetContractBucket):
+		return data.assetContracts
+	default:
+		return data.oracles
+	}
+}
+
+//TestContractStore runs testContractStore against ContractLibrary and MemoryContractStore
+func TestContractStore(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"ContractLibrary", func(t *testing.T) {
+			db, err := createOrOpenDB("./testContractStore")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			CreateSmartContractBucket(db)
+
+			db.Update(func(tx database.Tx) error {
+				put := func(bucket, key, record []byte) {
+					tx.Data().Bucket(bucket).Put(key, record)
+				}
+				testContractStore(t, NewContractLibrary(tx, false), NewContractLibrary(tx, true), put)
+				return nil
+			})
+		}},
+		{"MemoryContractStore", func(t *testing.T) {
+			store := NewMemoryContractStore()
+			put := func(bucket, key, record []byte) {
+				memoryBucket(store.data, bucket)[string(key)] = record
+			}
+			testContractStore(t, store, store.ReadOnly(), put)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, test.run)
+	}
+}
@@ -0,0 +1,243 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/SHDMT/gravity/infrastructure/database"
+	"github.com/SHDMT/gravity/infrastructure/log"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+)
+
+//StorageVersion is the current storage version of smart contract buckets
+const StorageVersion = 5
+
+//migration upgrades smart contract buckets from the previous version to version
+type migration struct {
+	version     uint32
+	description string
+	migrate     func(dbTx database.Tx) error
+}
+
+//migrations are the registered migration steps in version order
+var migrations = []migration{
+	{1, "build MCI, publisher and contract usage indexes", migrateIndexes},
+	{2, "add checksums to contract, asset and asset contract records", migrateChecksums},
+	{3, "count references to contracts", migrateContractRefs},
+	{4, "store asset contract definitions with their MCI", migrateAssetContractMCI},
+	{5, "store oracles with their MCI", migrateOracleMCI},
+}
+
+//migrateStorage runs the migration steps after the stored version in the transaction
+func migrateStorage(dbTx database.Tx) error {
+	version := dbFetchStorageVersion(dbTx)
+	if version > StorageVersion {
+		errString := fmt.Sprintf("storage version %d is newer than %d", version, StorageVersion)
+		return NewSmartContractError(ErrMigrateDB, errString, nil)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		log.Infof("migrate smart contract storage to version %d: %s", m.version, m.description)
+		err := m.migrate(dbTx)
+		if err != nil {
+			errString := fmt.Sprintf("Failed to migrate storage to version %d", m.version)
+			return NewSmartContractError(ErrMigrateDB, errString, err)
+		}
+		err = dbPutStorageVersion(dbTx, m.version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//migrateIndexes builds the indexes of records stored before they existed
+//corrupted records are logged and skipped, they are found by CheckConsistency
+func migrateIndexes(dbTx database.Tx) error {
+	data := dbTx.Data()
+
+	err := data.Bucket(dbnamespace.ContractBucket).ForEach(func(k, v []byte) error {
+		if len(v) < 8 {
+			log.Errorf("skip indexing corrupted contract %x", k)
+			return nil
+		}
+		return dbPutMCIIndex(dbTx, binary.BigEndian.Uint64(v), mciIndexContract, k)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = data.Bucket(dbnamespace.AssetBucket).ForEach(func(k, v []byte) error {
+		issueMessage := structure.NewIssueMessage()
+		if len(v) < 8 || issueMessage.Deserialize(v[8:]) != nil {
+			log.Errorf("skip indexing corrupted asset %x", k)
+			return nil
+		}
+		err := dbPutMCIIndex(dbTx, binary.BigEndian.Uint64(v), mciIndexAsset, k)
+		if err != nil {
+			return err
+		}
+		return dbPutPublisherIndex(dbTx, issueMessage.PublisherAddress, k)
+	})
+	if err != nil {
+		return err
+	}
+
+	//an asset contract key is the asset hash followed by the contract address of the same size
+	return data.Bucket(dbnamespace.AssetContractBucket).ForEach(func(k, v []byte) error {
+		half := len(k) / 2
+		return dbPutContractUsageIndex(dbTx, k[half:], k[:half])
+	})
+}
+
+//migrateChecksums appends checksums to the records stored without them
+func migrateChecksums(dbTx database.Tx) error {
+	buckets := [][]byte{dbnamespace.ContractBucket, dbnamespace.AssetBucket, dbnamespace.AssetContractBucket}
+	for _, name := range buckets {
+		bucket := dbTx.Data().Bucket(name)
+
+		keys := make([][]byte, 0)
+		values := make([][]byte, 0)
+		err := bucket.ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte(nil), k...))
+			values = append(values, withChecksum(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			err = bucket.Put(key, values[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//migrateContractRefs counts the asset contract definitions and assets referring to each contract
+//corrupted records are skipped, they are found by CheckConsistency
+func migrateContractRefs(dbTx database.Tx) error {
+	counts := make(map[string]uint32)
+
+	//an asset contract key is the asset hash followed by the contract address of the same size
+	err := dbTx.Data().Bucket(dbnamespace.AssetContractBucket).ForEach(func(k, v []byte) error {
+		counts[string(k[len(k)/2:])]++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = dbTx.Data().Bucket(dbnamespace.AssetBucket).ForEach(func(k, v []byte) error {
+		value, err := verifyChecksum(k, v)
+		if err != nil || len(value) < 8 {
+			return nil
+		}
+		issueMessage := structure.NewIssueMessage()
+		if issueMessage.Deserialize(value[8:]) != nil {
+			return nil
+		}
+		for _, contractDef := range issueMessage.Contracts {
+			counts[string(contractDef.Address)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for contract, count := range counts {
+		err = dbPutContractRefCount(dbTx, []byte(contract), count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//migrateAssetContractMCI prefixes the asset contract definitions with the MCI of their asset and indexes them
+//corrupted records are left as they are, they are found by CheckConsistency
+func migrateAssetContractMCI(dbTx database.Tx) error {
+	bucket := dbTx.Data().Bucket(dbnamespace.AssetContractBucket)
+
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+	err := bucket.ForEach(func(k, v []byte) error {
+		value, err := verifyChecksum(k, v)
+		if err != nil {
+			return nil
+		}
+		keys = append(keys, append([]byte(nil), k...))
+		values = append(values, append([]byte(nil), value...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		//an asset contract key is the asset hash followed by the contract address of the same size
+		mci := dbFetchAssetMCI(dbTx, key[:len(key)/2])
+		buf := make([]byte, 8, 8+len(values[i]))
+		binary.BigEndian.PutUint64(buf, mci)
+		err = dbPutAssetContract(dbTx, key, append(buf, values[i]...))
+		if err != nil {
+			return err
+		}
+		err = dbPutMCIIndex(dbTx, mci, mciIndexAssetContract, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//migrateOracleMCI prefixes the oracles with MCI 0 and indexes them, the MCI oracles were registered at is unknown
+//so they are kept by any rollback
+func migrateOracleMCI(dbTx database.Tx) error {
+	bucket := dbTx.Data().Bucket(dbnamespace.OracleBucket)
+
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+	err := bucket.ForEach(func(k, v []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		values = append(values, append(make([]byte, 8), v...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		err = dbPutOracle(dbTx, key, values[i])
+		if err != nil {
+			return err
+		}
+		err = dbPutMCIIndex(dbTx, 0, mciIndexOracle, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/infrastructure/database"
+	"github.com/SHDMT/gravity/platform/smartcontract/internal/dbnamespace"
+)
+
+//createLegacyDB creates a fixture database in the format before storage versioning
+//it holds the contract, assetContract and asset buckets only, with mci | Serialize() values
+//the database left by a previous run is removed first
+func createLegacyDB(t *testing.T, dbPath string) (database.Db, hash.HashType, hash.HashType, hash.HashType) {
+	os.RemoveAll(dbPath)
+	db, err := createOrOpenDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract := CreateContract0()
+	asset := FakeRandomHash()
+	msg := FakeIssueMessage()
+	err = db.Update(func(tx database.Tx) error {
+		for _, bucket := range [][]byte{dbnamespace.ContractBucket, dbnamespace.AssetContractBucket, dbnamespace.AssetBucket} {
+			if _, err := tx.Data().CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		mci := make([]byte, 8)
+		binary.BigEndian.PutUint64(mci, 20)
+		addr := contract.CalcAddress()
+		tx.Data().Bucket(dbnamespace.ContractBucket).Put(addr, append(mci, contract.Serialize()...))
+		tx.Data().Bucket(dbnamespace.AssetBucket).Put(asset, append(mci, msg.Serialize()...))
+		def := CreateRandomContractDef(addr)
+		return tx.Data().Bucket(dbnamespace.AssetContractBucket).Put(append(append([]byte(nil), asset...), addr...), def.Serialize())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, contract.CalcAddress(), asset, msg.PublisherAddress
+}
+
+func TestMigrateLegacyDB(t *testing.T) {
+	db, contract, asset, publisher := createLegacyDB(t, "./testMigrateLegacy")
+	defer os.RemoveAll("./testMigrateLegacy")
+	defer db.Close()
+	//an undecodable legacy asset doesn't stop the migration
+	corrupted := FakeRandomHash()
+	//an oracle registered before oracles were stored with their mci
+	oracle := &Oracle{Scheme: 1, PubKey: FakeRandomHash(), Description: "price feed"}
+	db.Update(func(tx database.Tx) error {
+		oracleBucket, err := tx.Data().CreateBucket(dbnamespace.OracleBucket)
+		if err != nil {
+			return err
+		}
+		err = oracleBucket.Put(oracle.ID(), oracle.Serialize())
+		if err != nil {
+			return err
+		}
+		return tx.Data().Bucket(dbnamespace.AssetBucket).Put(corrupted, append(make([]byte, 8), "not an asset"...))
+	})
+
+	err := CreateSmartContractBucket(db)
+	if err != nil {
+		t.Fatal("Can't migrate legacy db, ", err)
+	}
+
+	db.Update(func(tx database.Tx) error {
+		if version := dbFetchStorageVersion(tx); version != StorageVersion {
+			t.Errorf("Storage version is %d after migration.", version)
+		}
+
+		library := NewContractLibrary(tx, false)
+		assets, _ := library.ListAssetsByPublisher(publisher)
+		if len(assets) != 1 || !assets[0].IsEqual(asset) {
+			t.Error("Legacy asset should be indexed by publisher.")
+		}
+		assets, _ = library.ListAssetsByContract(contract)
+		if len(assets) != 1 || !assets[0].IsEqual(asset) {
+			t.Error("Legacy asset should be indexed by contract.")
+		}
+
+		_, mci, err := library.LoadContract(contract)
+		if err != nil || mci != 20 {
+			t.Errorf("Can't load legacy contract, %v", err)
+		}
+
+		report, err := library.CheckConsistency(false)
+		if err != nil || len(report.Corrupted) != 1 || !report.Corrupted[0].Key.IsEqual(corrupted) {
+			t.Errorf("Undecodable legacy asset should be left for the consistency check, %v", err)
+		}
+
+		if _, err := library.LoadAssetContractDef(asset, contract); err != nil {
+			t.Errorf("Can't load legacy contract definition, %v", err)
+		}
+
+		if dbOracle, err := library.LoadOracle(oracle.ID()); err != nil || dbOracle.Description != oracle.Description {
+			t.Errorf("Can't load legacy oracle, %v", err)
+		}
+
+		if library.ContractRefCount(contract) != 1 {
+			t.Errorf("Legacy contract has %d references, expect 1.", library.ContractRefCount(contract))
+		}
+
+		//the legacy records are indexed by their mci
+		err = library.RollbackTo(20)
+		if err != nil || !library.HasContract(contract) || !library.HasAsset(asset) {
+			t.Error("Legacy records at mci 20 should be kept.")
+		}
+		err = library.RollbackTo(19)
+		if err != nil || library.HasContract(contract) || library.HasAsset(asset) || library.HasAssetContract(asset, contract) {
+			t.Error("Legacy records at mci 20 should be rolled back.")
+		}
+		if !library.HasOracle(oracle.ID()) {
+			t.Error("Legacy oracle should be kept by any rollback.")
+		}
+		return nil
+	})
+
+	//migrating again does nothing
+	err = CreateSmartContractBucket(db)
+	if err != nil {
+		t.Error("Can't open migrated db, ", err)
+	}
+}
+
+func TestMigrateNewDB(t *testing.T) {
+	//the database is left at a newer version, so it is never reused
+	os.RemoveAll("./testMigrateNew")
+	defer os.RemoveAll("./testMigrateNew")
+	db, err := createOrOpenDB("./testMigrateNew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = CreateSmartContractBucket(db)
+	if err != nil {
+		t.Fatal("Can't create db, ", err)
+	}
+
+	steps := 0
+	saved := migrations
+	defer func() { migrations = saved }()
+	migrations = []migration{{1, "count", func(database.Tx) error { steps++; return nil }}}
+
+	db.View(func(tx database.Tx) error {
+		if version := dbFetchStorageVersion(tx); version != StorageVersion {
+			t.Errorf("New db storage version is %d.", version)
+		}
+		return nil
+	})
+	CreateSmartContractBucket(db)
+	if steps != 0 {
+		t.Error("New db should not be migrated.")
+	}
+
+	//a database of a newer version is refused
+	db.Update(func(tx database.Tx) error {
+		return dbPutStorageVersion(tx, StorageVersion+1)
+	})
+	if CreateSmartContractBucket(db) == nil {
+		t.Error("Newer storage version should be refused.")
+	}
+}
@@ -0,0 +1,68 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"encoding/binary"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/pkg/errors"
+)
+
+//Oracle is an identity signing attestations of off-chain data used by contracts
+type Oracle struct {
+	//Scheme is the header byte of the signature scheme of PubKey
+	Scheme byte
+	//PubKey is the marshaled public key without scheme header
+	PubKey []byte
+	//Description tells who runs the oracle and what it attests
+	Description string
+}
+
+//PublicKey returns the public key with scheme header, the format taken by the verify builtins
+func (oracle *Oracle) PublicKey() []byte {
+	return append([]byte{oracle.Scheme}, oracle.PubKey...)
+}
+
+//ID returns the identification of oracle, it is the hash of the public key with scheme header
+func (oracle *Oracle) ID() hash.HashType {
+	return hash.Sum256(oracle.PublicKey())
+}
+
+//Serialize returns the bytes of oracle
+//scheme(1) | length of public key(2) | public key | description
+func (oracle *Oracle) Serialize() []byte {
+	buf := make([]byte, 3, 3+len(oracle.PubKey)+len(oracle.Description))
+	buf[0] = oracle.Scheme
+	binary.BigEndian.PutUint16(buf[1:], uint16(len(oracle.PubKey)))
+	buf = append(buf, oracle.PubKey...)
+	buf = append(buf, oracle.Description...)
+	return buf
+}
+
+//Deserialize parses the bytes of oracle
+func (oracle *Oracle) Deserialize(data []byte) error {
+	if len(data) < 3 {
+		return errors.Errorf("oracle data is too short")
+	}
+	l := int(binary.BigEndian.Uint16(data[1:]))
+	if len(data) < 3+l {
+		return errors.Errorf("oracle public key is too short")
+	}
+	oracle.Scheme = data[0]
+	oracle.PubKey = append([]byte(nil), data[3:3+l]...)
+	oracle.Description = string(data[3+l:])
+	return nil
+}
@@ -0,0 +1,341 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/pkg/errors"
+)
+
+//SnapshotVersion is the version of snapshot format
+const SnapshotVersion = 1
+
+//snapshotMagic starts every snapshot
+var snapshotMagic = []byte("GSCS")
+
+//The followings define the kinds of snapshot records
+const (
+	snapshotContract byte = iota + 1
+	snapshotAsset
+	snapshotAssetContract
+	snapshotOracle
+)
+
+const (
+	//snapshotHeaderSize is magic(4) | version(2) | MCI(8) | 4 record counts(16) | checksum(4)
+	snapshotHeaderSize = 34
+	//snapshotRecordHeaderSize is kind(1) | key length(4) | value length(4)
+	snapshotRecordHeaderSize = 9
+	//maxSnapshotKeySize and maxSnapshotValueSize bound the allocations of a malformed snapshot
+	maxSnapshotKeySize   = 1024
+	maxSnapshotValueSize = 16 * 1024 * 1024
+)
+
+//SnapshotHeader describes the state in a snapshot
+type SnapshotHeader struct {
+	Version        uint16
+	MCI            uint64
+	Contracts      uint32
+	Assets         uint32
+	AssetContracts uint32
+	Oracles        uint32
+}
+
+//snapshot format:
+//header | records | sha256 of header and records
+//a record is kind(1) | key length(4) | value length(4) | key | value | checksum(4)
+//contract, asset, asset contract and oracle values are MCI(8) | serialized object, checksums are CRC-32C of the preceding bytes
+
+func (header *SnapshotHeader) serialize() []byte {
+	buf := make([]byte, snapshotHeaderSize)
+	copy(buf, snapshotMagic)
+	binary.BigEndian.PutUint16(buf[4:], header.Version)
+	binary.BigEndian.PutUint64(buf[6:], header.MCI)
+	binary.BigEndian.PutUint32(buf[14:], header.Contracts)
+	binary.BigEndian.PutUint32(buf[18:], header.Assets)
+	binary.BigEndian.PutUint32(buf[22:], header.AssetContracts)
+	binary.BigEndian.PutUint32(buf[26:], header.Oracles)
+	binary.BigEndian.PutUint32(buf[30:], crc32.Checksum(buf[:30], checksumTable))
+	return buf
+}
+
+func (header *SnapshotHeader) deserialize(buf []byte) error {
+	if !bytes.Equal(buf[:4], snapshotMagic) {
+		return snapshotError("not a snapshot", nil)
+	}
+	if crc32.Checksum(buf[:30], checksumTable) != binary.BigEndian.Uint32(buf[30:]) {
+		return snapshotError("header checksum mismatch", nil)
+	}
+	header.Version = binary.BigEndian.Uint16(buf[4:])
+	if header.Version != SnapshotVersion {
+		return snapshotError(fmt.Sprintf("unknown snapshot version %d", header.Version), nil)
+	}
+	header.MCI = binary.BigEndian.Uint64(buf[6:])
+	header.Contracts = binary.BigEndian.Uint32(buf[14:])
+	header.Assets = binary.BigEndian.Uint32(buf[18:])
+	header.AssetContracts = binary.BigEndian.Uint32(buf[22:])
+	header.Oracles = binary.BigEndian.Uint32(buf[26:])
+	return nil
+}
+
+func snapshotError(des string, err error) error {
+	return NewSmartContractError(ErrSnapshot, des, err)
+}
+
+func writeSnapshotRecord(w io.Writer, kind byte, key, value []byte) error {
+	buf := make([]byte, snapshotRecordHeaderSize, snapshotRecordHeaderSize+len(key)+len(value)+checksumSize)
+	buf[0] = kind
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(key)))
+	binary.BigEndian.PutUint32(buf[5:], uint32(len(value)))
+	buf = append(append(buf, key...), value...)
+	buf = buf[:len(buf)+checksumSize]
+	binary.BigEndian.PutUint32(buf[len(buf)-checksumSize:], crc32.Checksum(buf[:len(buf)-checksumSize], checksumTable))
+	_, err := w.Write(buf)
+	return err
+}
+
+func readSnapshotRecord(r io.Reader) (byte, []byte, []byte, error) {
+	head := make([]byte, snapshotRecordHeaderSize)
+	_, err := io.ReadFull(r, head)
+	if err != nil {
+		return 0, nil, nil, snapshotError("failed to read record", err)
+	}
+	keySize := binary.BigEndian.Uint32(head[1:])
+	valueSize := binary.BigEndian.Uint32(head[5:])
+	if keySize > maxSnapshotKeySize || valueSize > maxSnapshotValueSize {
+		return 0, nil, nil, snapshotError("record is too large", nil)
+	}
+
+	buf := make([]byte, snapshotRecordHeaderSize+int(keySize)+int(valueSize)+checksumSize)
+	copy(buf, head)
+	_, err = io.ReadFull(r, buf[snapshotRecordHeaderSize:])
+	if err != nil {
+		return 0, nil, nil, snapshotError("failed to read record", err)
+	}
+	body := buf[:len(buf)-checksumSize]
+	if crc32.Checksum(body, checksumTable) != binary.BigEndian.Uint32(buf[len(body):]) {
+		return 0, nil, nil, snapshotError("record checksum mismatch", nil)
+	}
+	key := body[snapshotRecordHeaderSize : snapshotRecordHeaderSize+keySize]
+	return head[0], key, body[snapshotRecordHeaderSize+keySize:], nil
+}
+
+//snapshotScan visits the records of the state at mci, the same records RollbackTo(mci) would keep
+//a contract keeps the MCI it was first stored at, an asset, a contract definition or an oracle saved again
+//above mci is visited with the value it had at mci, which is found by its undo records
+func (library *ContractLibrary) snapshotScan(mci uint64, fn func(kind byte, key, value []byte) error) error {
+	err := dbForEachContract(library.tx, func(k, v []byte) error {
+		if len(v) < 8 || binary.BigEndian.Uint64(v) > mci {
+			return nil
+		}
+		return fn(snapshotContract, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	err = dbForEachAsset(library.tx, func(k, v []byte) error {
+		v = library.valueAt(mciIndexAsset, k, v, mci)
+		if v == nil {
+			return nil
+		}
+		return fn(snapshotAsset, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	err = dbForEachAssetContract(library.tx, func(k, v []byte) error {
+		v = library.valueAt(mciIndexAssetContract, k, v, mci)
+		if v == nil {
+			return nil
+		}
+		return fn(snapshotAssetContract, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	return dbForEachOracle(library.tx, func(k, v []byte) error {
+		v = library.valueAt(mciIndexOracle, k, v, mci)
+		if v == nil {
+			return nil
+		}
+		return fn(snapshotOracle, k, v)
+	})
+}
+
+//valueAt returns the value the record of kind at key had at mci, value is its stored value
+//it returns nil if the record had no value at mci
+func (library *ContractLibrary) valueAt(kind byte, key, value []byte, mci uint64) []byte {
+	for len(value) >= 8 && binary.BigEndian.Uint64(value) > mci {
+		value = dbFetchUndo(library.tx, kind, key, binary.BigEndian.Uint64(value))
+	}
+	if len(value) < 8 {
+		return nil
+	}
+	return value
+}
+
+//ExportSnapshot writes the contracts, assets, asset contracts and oracles of the state at atMCI to w
+//records saved again above atMCI are written with the values RollbackTo(atMCI) would restore
+func (library *ContractLibrary) ExportSnapshot(w io.Writer, atMCI uint64) (*SnapshotHeader, error) {
+	header := &SnapshotHeader{
+		Version: SnapshotVersion,
+		MCI:     atMCI,
+	}
+	err := library.snapshotScan(atMCI, func(kind byte, key, value []byte) error {
+		switch kind {
+		case snapshotContract:
+			header.Contracts++
+		case snapshotAsset:
+			header.Assets++
+		case snapshotAssetContract:
+			header.AssetContracts++
+		case snapshotOracle:
+			header.Oracles++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.New()
+	mw := io.MultiWriter(w, digest)
+	_, err = mw.Write(header.serialize())
+	if err != nil {
+		return nil, snapshotError("failed to write header", err)
+	}
+	err = library.snapshotScan(atMCI, func(kind byte, key, value []byte) error {
+		return writeSnapshotRecord(mw, kind, key, value)
+	})
+	if err != nil {
+		return nil, snapshotError("failed to write records", err)
+	}
+	_, err = w.Write(digest.Sum(nil))
+	if err != nil {
+		return nil, snapshotError("failed to write digest", err)
+	}
+
+	return header, nil
+}
+
+//ImportSnapshot reads a snapshot from r and saves its records, the indexes of records are built as they are saved
+//records are saved as they are read, so the transaction must be discarded when an error is returned
+func (library *ContractLibrary) ImportSnapshot(r io.Reader) (*SnapshotHeader, error) {
+	if library.readOnly {
+		return nil, errors.Errorf(writePermissionsError)
+	}
+
+	digest := sha256.New()
+	tr := io.TeeReader(r, digest)
+
+	buf := make([]byte, snapshotHeaderSize)
+	_, err := io.ReadFull(tr, buf)
+	if err != nil {
+		return nil, snapshotError("failed to read header", err)
+	}
+	header := new(SnapshotHeader)
+	err = header.deserialize(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[byte]uint32)
+	total := uint64(header.Contracts) + uint64(header.Assets) + uint64(header.AssetContracts) + uint64(header.Oracles)
+	for i := uint64(0); i < total; i++ {
+		kind, key, value, err := readSnapshotRecord(tr)
+		if err != nil {
+			return nil, err
+		}
+		err = library.importSnapshotRecord(kind, key, value)
+		if err != nil {
+			return nil, err
+		}
+		counts[kind]++
+	}
+	if counts[snapshotContract] != header.Contracts || counts[snapshotAsset] != header.Assets ||
+		counts[snapshotAssetContract] != header.AssetContracts || counts[snapshotOracle] != header.Oracles {
+		return nil, snapshotError("record counts mismatch", nil)
+	}
+
+	sum := digest.Sum(nil)
+	_, err = io.ReadFull(r, buf[:sha256.Size])
+	if err != nil {
+		return nil, snapshotError("failed to read digest", err)
+	}
+	if !bytes.Equal(sum, buf[:sha256.Size]) {
+		return nil, snapshotError("digest mismatch", nil)
+	}
+
+	return header, nil
+}
+
+//importSnapshotRecord decodes a record of the snapshot and saves it, a record not matching its key is refused
+func (library *ContractLibrary) importSnapshotRecord(kind byte, key, value []byte) error {
+	if len(value) < 8 {
+		return snapshotError(fmt.Sprintf("record %v is too short", key), nil)
+	}
+	mci := binary.BigEndian.Uint64(value)
+	value = value[8:]
+
+	switch kind {
+	case snapshotContract:
+		contract := structure.NewContract()
+		err := contract.Deserialize(value)
+		if err != nil {
+			return snapshotError(fmt.Sprintf("failed to decode contract %v", key), err)
+		}
+		if !bytes.Equal(contract.CalcAddress(), key) {
+			return snapshotError(fmt.Sprintf("contract %v doesn't match its address", key), nil)
+		}
+		return library.SaveContract(contract, mci)
+	case snapshotAsset:
+		issueMessage := structure.NewIssueMessage()
+		err := issueMessage.Deserialize(value)
+		if err != nil {
+			return snapshotError(fmt.Sprintf("failed to decode asset %v", key), err)
+		}
+		return library.SaveAsset(hash.HashType(key), issueMessage, mci)
+	case snapshotAssetContract:
+		contractDef := new(structure.ContractDef)
+		err := contractDef.Deserialize(value)
+		if err != nil {
+			return snapshotError(fmt.Sprintf("failed to decode asset contract %v", key), err)
+		}
+		if len(key) != 2*len(contractDef.Address) || !bytes.HasSuffix(key, contractDef.Address) {
+			return snapshotError(fmt.Sprintf("asset contract %v doesn't match its address", key), nil)
+		}
+		asset := append([]byte(nil), key[:len(contractDef.Address)]...)
+		return library.SaveAssetContractDefAt(asset, contractDef, mci)
+	case snapshotOracle:
+		oracle := new(Oracle)
+		err := oracle.Deserialize(value)
+		if err != nil {
+			return snapshotError(fmt.Sprintf("failed to decode oracle %v", key), err)
+		}
+		if !bytes.Equal(oracle.ID(), key) {
+			return snapshotError(fmt.Sprintf("oracle %v doesn't match its ID", key), nil)
+		}
+		return library.SaveOracle(oracle, mci)
+	}
+	return snapshotError(fmt.Sprintf("unknown record kind %d", kind), nil)
+}
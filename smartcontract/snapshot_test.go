@@ -0,0 +1,141 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/database"
+)
+
+func TestContractLibrary_Snapshot(t *testing.T) {
+	source, err := createOrOpenDB("./testSnapshotSource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	CreateSmartContractBucket(source)
+
+	contract0, contract1 := CreateContract0(), CreateContract1()
+	address0, address1 := contract0.CalcAddress(), contract1.CalcAddress()
+	asset0, asset1 := FakeRandomHash(), FakeRandomHash()
+	msg0 := FakeIssueMessage()
+	oracle := &Oracle{Scheme: 1, PubKey: FakeRandomHash(), Description: "price feed"}
+	lateOracle := &Oracle{Scheme: 1, PubKey: FakeRandomHash(), Description: "late feed"}
+
+	var snapshot bytes.Buffer
+	source.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+		library.SaveContract(contract0, 1)
+		library.SaveContract(contract1, 3)
+		library.SaveAsset(asset0, msg0, 2)
+		library.SaveAsset(asset1, FakeIssueMessage(), 3)
+		library.SaveAssetContractDefAt(asset0, CreateRandomContractDef(address0), 2)
+		library.SaveAssetContractDefAt(asset1, CreateRandomContractDef(address1), 3)
+		library.SaveOracle(oracle, 2)
+		library.SaveOracle(lateOracle, 3)
+		//a contract stored again later keeps the MCI it existed at
+		library.SaveContract(contract0, 3)
+		//an asset and an oracle saved again later are exported with their values at the snapshot mci
+		library.SaveAsset(asset0, FakeIssueMessage(), 3)
+		library.SaveOracle(&Oracle{Scheme: 1, PubKey: oracle.PubKey, Description: "new feed"}, 3)
+
+		header, err := library.ExportSnapshot(&snapshot, 2)
+		if err != nil {
+			t.Fatal("Can't export snapshot, ", err)
+		}
+		expect := SnapshotHeader{Version: SnapshotVersion, MCI: 2, Contracts: 1, Assets: 1, AssetContracts: 1, Oracles: 1}
+		if *header != expect {
+			t.Errorf("Unexpected snapshot header %+v", header)
+		}
+		return nil
+	})
+
+	target, err := createOrOpenDB("./testSnapshotTarget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	CreateSmartContractBucket(target)
+
+	target.Update(func(tx database.Tx) error {
+		library := NewContractLibrary(tx, false)
+		header, err := library.ImportSnapshot(bytes.NewReader(snapshot.Bytes()))
+		if err != nil {
+			t.Fatal("Can't import snapshot, ", err)
+		}
+		if header.MCI != 2 {
+			t.Errorf("Snapshot mci is %d, expect 2.", header.MCI)
+		}
+
+		contract, mci, err := library.LoadContract(address0)
+		if err != nil || mci != 1 || !reflect.DeepEqual(contract.Serialize(), contract0.Serialize()) {
+			t.Errorf("Can't load imported contract, %v", err)
+		}
+		if library.HasContract(address1) || library.HasAsset(asset1) {
+			t.Error("Records above snapshot mci should not be imported.")
+		}
+		if _, _, err := library.LoadAssetContract(asset0, address0); err != nil {
+			t.Errorf("Can't load imported asset contract, %v", err)
+		}
+		msg, mci, err := library.LoadAsset(asset0)
+		if err != nil || mci != 2 || !reflect.DeepEqual(msg.Serialize(), msg0.Serialize()) {
+			t.Errorf("Asset0 should be imported with its value at snapshot mci, %v", err)
+		}
+		dbOracle, err := library.LoadOracle(oracle.ID())
+		if err != nil || !reflect.DeepEqual(dbOracle, oracle) {
+			t.Errorf("Oracle should be imported with its value at snapshot mci, %v", err)
+		}
+		if library.HasOracle(lateOracle.ID()) {
+			t.Error("Oracle above snapshot mci should not be imported.")
+		}
+
+		//the indexes are built on import
+		assets, _ := library.ListAssetsByPublisher(msg0.PublisherAddress)
+		if len(assets) != 1 {
+			t.Error("Imported asset should be indexed by publisher.")
+		}
+		library.RollbackTo(1)
+		if library.HasAsset(asset0) || library.HasOracle(oracle.ID()) || !library.HasContract(address0) {
+			t.Error("Imported records should be indexed by mci.")
+		}
+		return nil
+	})
+
+	//a damaged snapshot is refused
+	damaged := [][]byte{
+		snapshot.Bytes()[:snapshot.Len()-1],
+		snapshot.Bytes()[:snapshot.Len()/2],
+		append([]byte(nil), snapshot.Bytes()...),
+	}
+	damaged[2][snapshotHeaderSize+20] ^= 0xff
+	for i, data := range damaged {
+		target.Update(func(tx database.Tx) error {
+			_, err := NewContractLibrary(tx, false).ImportSnapshot(bytes.NewReader(data))
+			if err == nil {
+				t.Errorf("Damaged snapshot %d should be refused.", i)
+			}
+			return nil
+		})
+	}
+
+	//a header of an unknown version is refused
+	for _, version := range []uint16{0, SnapshotVersion + 1} {
+		header := &SnapshotHeader{Version: version, MCI: 2}
+		if new(SnapshotHeader).deserialize(header.serialize()) == nil {
+			t.Errorf("Snapshot of version %d should be refused.", version)
+		}
+	}
+}
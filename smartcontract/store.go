@@ -0,0 +1,104 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package smartcontract
+
+import (
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/pkg/errors"
+)
+
+//ContractStore is the storage of contracts, assets and oracles used by the smart contract platform
+//ContractLibrary stores them in the database, MemoryContractStore keeps the same records in memory
+type ContractStore interface {
+	SaveContract(contract *structure.Contract, mci uint64) error
+	LoadContract(address hash.HashType) (*structure.Contract, uint64, error)
+	HasContract(address hash.HashType) bool
+	RemoveContract(address hash.HashType) error
+	ListContracts() ([]hash.HashType, error)
+	ListContractsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error)
+
+	SaveAssetContractDef(asset hash.HashType, contractDef *structure.ContractDef) error
+	SaveAssetContractDefAt(asset hash.HashType, contractDef *structure.ContractDef, mci uint64) error
+	LoadAssetContractDef(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, error)
+	LoadAssetContract(asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error)
+	HasAssetContract(asset hash.HashType, addr hash.HashType) bool
+	RemoveAssetContract(asset hash.HashType, address hash.HashType) error
+	ListAssetContractDefs(asset hash.HashType) ([]*structure.ContractDef, error)
+
+	SaveAsset(unithash hash.HashType, asset *structure.IssueMessage, mci uint64) error
+	LoadAsset(assetHash hash.HashType) (*structure.IssueMessage, uint64, error)
+	HasAsset(asset hash.HashType) bool
+	RemoveAsset(asset hash.HashType) error
+	ListAssets() ([]hash.HashType, error)
+	ListAssetsPaged(cursor hash.HashType, limit int) ([]hash.HashType, hash.HashType, error)
+	ListAssetsByPublisher(publisher hash.HashType) ([]hash.HashType, error)
+	ListAssetsByContract(contract hash.HashType) ([]hash.HashType, error)
+
+	RollbackTo(mci uint64) error
+
+	SaveOracle(oracle *Oracle, mci uint64) error
+	LoadOracle(id hash.HashType) (*Oracle, error)
+	FetchOracle(id hash.HashType) []byte
+	HasOracle(id hash.HashType) bool
+	RemoveOracle(id hash.HashType) error
+
+	QuarantineContract(address hash.HashType) error
+	QuarantineAsset(asset hash.HashType) error
+	QuarantineAssetContract(asset hash.HashType, address hash.HashType) error
+	ListQuarantined() ([]*QuarantinedRecord, error)
+
+	ContractRefCount(address hash.HashType) uint32
+	RetainContract(address hash.HashType) error
+	ReleaseContract(address hash.HashType) error
+	EnableGarbageCollection() error
+	CollectGarbage(beforeMCI uint64) ([]hash.HashType, error)
+}
+
+var (
+	_ ContractStore = (*ContractLibrary)(nil)
+	_ ContractStore = (*MemoryContractStore)(nil)
+	_ ContractStore = (*CachedContractStore)(nil)
+)
+
+//loadAssetContract reads the contract definition associated with asset and the contract it refers to
+func loadAssetContract(store ContractStore, asset hash.HashType, addr hash.HashType) (*structure.ContractDef, *structure.Contract, error) {
+	contractDef, err := store.LoadAssetContractDef(asset, addr)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !store.HasContract(contractDef.Address) {
+		return contractDef, nil, errors.Errorf("Can't find contract from Contract library.")
+	}
+
+	contract, _, err := store.LoadContract(contractDef.Address)
+
+	if err != nil {
+		return contractDef, nil, err
+	}
+
+	return contractDef, contract, err
+}
+
+//fetchOracle returns the public key with scheme header of oracle, or nil if the oracle doesn't exist
+func fetchOracle(store ContractStore, id hash.HashType) []byte {
+	oracle, err := store.LoadOracle(id)
+	if err != nil {
+		return nil
+	}
+	return oracle.PublicKey()
+}
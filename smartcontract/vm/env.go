@@ -15,6 +15,7 @@
 package vm
 
 import (
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
 	"github.com/SHDMT/gravity/platform/consensus/structure"
 )
 
@@ -43,6 +44,10 @@ type Context struct {
 	//FetchPrevOut is a callback function to fetch previous output associated with current input
 	FetchPrevOut func(input *structure.ContractInput) *structure.ContractOutput
 
+	//FetchOracle is a callback function to fetch the public key with scheme header of a registered oracle
+	//it returns nil if the oracle isn't registered
+	FetchOracle func(id hash.HashType) []byte
+
 	//MCI is Main Chain Index of DAG that is used when execute contract
 	MCI uint64
 
@@ -64,6 +69,8 @@ type Config struct {
 	MaxStep uint32
 	//Mode marks execute mode of VM
 	Mode byte
+	//MaxOracleAge is the max MCIs an oracle attestation is accepted after, zero means the VM default
+	MaxOracleAge uint64
 }
 
 //NewContext creates a new smart contract runtime environment object
@@ -0,0 +1,175 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+)
+
+//SigCheck is a signature check a contract will do
+type SigCheck struct {
+	//PubKey is the public key with scheme header
+	PubKey []byte
+	Hash   []byte
+	Sig    []byte
+}
+
+//BatchVerifier verifies the checks of one scheme together and returns the result of each check
+type BatchVerifier func(keys []asymmetric.PublicKey, hashes [][]byte, sigs [][]byte) []bool
+
+var (
+	batchLock      sync.RWMutex
+	batchVerifiers = map[byte]BatchVerifier{
+		SchemeSchnorr: verifySchnorrBatch,
+	}
+)
+
+//RegisterBatchVerifier registers the batch verification of a scheme
+//checks of schemes without batch verifier are verified one by one in parallel
+func RegisterBatchVerifier(header byte, verifier BatchVerifier) error {
+	batchLock.Lock()
+	defer batchLock.Unlock()
+	if _, ok := batchVerifiers[header]; ok {
+		return ErrSchemeRegistered
+	}
+	batchVerifiers[header] = verifier
+	return nil
+}
+
+//verifyEach verifies the checks one by one, it is the fallback of a batch verifier
+func verifyEach(keys []asymmetric.PublicKey, hashes [][]byte, sigs [][]byte) []bool {
+	results := make([]bool, len(keys))
+	for i, key := range keys {
+		results[i] = key.Verify(hashes[i], sigs[i])
+	}
+	return results
+}
+
+//UnitSigChecks collects the checks of the authors' signatures of the unit
+//contracts check them by (verify pk (getCurUnitHashToSign) (getSig pk))
+//the author signatures are the only signatures the unit carries for its contracts,
+//the checks of signatures passed as parameters are only known by the contracts using them
+func UnitSigChecks(unit *structure.Unit) []SigCheck {
+	h := unit.GetHashToSign()
+	checks := make([]SigCheck, 0, len(unit.Authors))
+	for _, author := range unit.Authors {
+		checks = append(checks, SigCheck{
+			PubKey: author.Definition,
+			Hash:   h,
+			Sig:    author.Authentifiers,
+		})
+	}
+	return checks
+}
+
+//pendingCheck is a check missed by the cache
+type pendingCheck struct {
+	index  int
+	pubKey asymmetric.PublicKey
+}
+
+//VerifyBatch verifies the checks with workers goroutines and fills the cache with the valid ones
+//it returns the result of each check, checks whose public key can't be parsed are false
+//a zero workers means the number of CPUs
+func (c *SigCache) VerifyBatch(checks []SigCheck, workers int) []bool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]bool, len(checks))
+	//group the checks missed by the cache by scheme
+	groups := make(map[byte][]pendingCheck)
+	for i, check := range checks {
+		pubKey, err := ParsePublicKey(check.PubKey)
+		if err != nil {
+			continue
+		}
+		if c.size > 0 {
+			if c.lookup(sigCacheKey(check.PubKey, check.Hash, check.Sig)) {
+				results[i] = true
+				continue
+			}
+		}
+		groups[check.PubKey[0]] = append(groups[check.PubKey[0]], pendingCheck{i, pubKey})
+	}
+
+	jobs := make(chan func())
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+	for header, group := range groups {
+		batchLock.RLock()
+		verifier, ok := batchVerifiers[header]
+		batchLock.RUnlock()
+		if ok {
+			group := group
+			jobs <- func() {
+				keys := make([]asymmetric.PublicKey, len(group))
+				hashes := make([][]byte, len(group))
+				sigs := make([][]byte, len(group))
+				for j, p := range group {
+					keys[j], hashes[j], sigs[j] = p.pubKey, checks[p.index].Hash, checks[p.index].Sig
+				}
+				res := verifier(keys, hashes, sigs)
+				//a verifier returning a wrong number of results can't be trusted
+				if len(res) != len(group) {
+					res = verifyEach(keys, hashes, sigs)
+				}
+				for j, p := range group {
+					results[p.index] = res[j]
+				}
+			}
+			continue
+		}
+		for _, p := range group {
+			p := p
+			jobs <- func() {
+				check := checks[p.index]
+				results[p.index] = p.pubKey.Verify(check.Hash, check.Sig)
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if c.size > 0 {
+		for _, group := range groups {
+			for _, p := range group {
+				if !results[p.index] {
+					continue
+				}
+				check := checks[p.index]
+				c.add(sigCacheKey(check.PubKey, check.Hash, check.Sig))
+			}
+		}
+	}
+	return results
+}
+
+//PreverifyUnit verifies the author signatures of the unit and the extra checks into the shared cache before its contracts run
+//LispVM does it when the unit of its context changes, the host may do it earlier with the checks it knows the contracts need
+func PreverifyUnit(unit *structure.Unit, workers int, extra ...SigCheck) {
+	sigCache.VerifyBatch(append(UnitSigChecks(unit), extra...), workers)
+}
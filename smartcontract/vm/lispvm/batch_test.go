@@ -0,0 +1,224 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+)
+
+//fakeSigChecks returns n valid ed25519 checks
+func fakeSigChecks(t *testing.T, header byte, n int) []SigCheck {
+	checks := make([]SigCheck, n)
+	for i := range checks {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal("generate key failed")
+		}
+		h := []byte{byte(i), 1, 2, 3}
+		checks[i] = SigCheck{PubKey: append([]byte{header}, pub...), Hash: h, Sig: ed25519.Sign(priv, h)}
+	}
+	return checks
+}
+
+func TestVerifyBatch(t *testing.T) {
+	checks := fakeSigChecks(t, SchemeEd25519, 16)
+	checks[3].Sig = checks[4].Sig
+	checks = append(checks, SigCheck{PubKey: []byte{7, 1}, Hash: []byte{1}, Sig: []byte{1}})
+
+	c := NewSigCache(100)
+	results := c.VerifyBatch(checks, 4)
+	for i, res := range results {
+		want := i != 3 && i != 16
+		if res != want {
+			t.Errorf("check %d should be %v", i, want)
+		}
+	}
+	//invalid checks and checks of unknown scheme are not cached
+	if stats := c.Stats(); stats.Entries != 15 || stats.Misses != 16 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+	//the valid ones hit the cache later
+	for i, check := range checks[:16] {
+		pubKey, _ := ParsePublicKey(check.PubKey)
+		if c.Verify(check.PubKey, pubKey, check.Hash, check.Sig) != (i != 3) {
+			t.Errorf("cached check %d is wrong", i)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 15 || stats.Misses != 17 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+}
+
+func TestRegisterBatchVerifier(t *testing.T) {
+	const header = 0x7e
+	err := RegisterScheme(header, func() asymmetric.PublicKey { return new(ed25519PublicKey) })
+	if err != nil {
+		t.Fatalf("register scheme failed, err= %v", err)
+	}
+	defer func() {
+		schemesLock.Lock()
+		delete(schemes, header)
+		schemesLock.Unlock()
+		batchLock.Lock()
+		delete(batchVerifiers, header)
+		batchLock.Unlock()
+	}()
+	calls := 0
+	err = RegisterBatchVerifier(header, func(keys []asymmetric.PublicKey, hashes [][]byte, sigs [][]byte) []bool {
+		calls++
+		results := make([]bool, len(keys))
+		for i := range keys {
+			results[i] = keys[i].Verify(hashes[i], sigs[i])
+		}
+		return results
+	})
+	if err != nil {
+		t.Fatalf("register batch verifier failed, err= %v", err)
+	}
+	if RegisterBatchVerifier(header, nil) != ErrSchemeRegistered {
+		t.Errorf("register batch verifier twice should fail")
+	}
+
+	checks := fakeSigChecks(t, header, 8)
+	for i, res := range NewSigCache(100).VerifyBatch(checks, 0) {
+		if !res {
+			t.Errorf("check %d should be valid", i)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("batch verifier should be called once, called %d", calls)
+	}
+}
+
+func TestVerifySchnorrBatch(t *testing.T) {
+	//BIP-340 test vectors 0 and 1
+	pks := []string{
+		"f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		"dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+	}
+	msgs := []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+	}
+	sigs := []string{
+		"e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca8215" +
+			"25f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+		"6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de3341" +
+			"8906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+	}
+	var keys []asymmetric.PublicKey
+	var hashes, signatures [][]byte
+	for i := 0; i < 6; i++ {
+		pk, _ := hex.DecodeString(pks[i%2])
+		pubKey, err := ParsePublicKey(append([]byte{SchemeSchnorr}, pk...))
+		if err != nil {
+			t.Fatalf("parse key failed, err= %v", err)
+		}
+		h, _ := hex.DecodeString(msgs[i%2])
+		sig, _ := hex.DecodeString(sigs[i%2])
+		keys, hashes, signatures = append(keys, pubKey), append(hashes, h), append(signatures, sig)
+	}
+	for i, res := range verifySchnorrBatch(keys, hashes, signatures) {
+		if !res {
+			t.Errorf("check %d should be valid", i)
+		}
+	}
+	//an invalid signature is found by the fallback
+	hashes[3] = hashes[0]
+	signatures[4] = signatures[4][:63]
+	for i, res := range verifySchnorrBatch(keys, hashes, signatures) {
+		if res != (i != 3 && i != 4) {
+			t.Errorf("check %d should be %v", i, !res)
+		}
+	}
+}
+
+func TestBatchVerifierResults(t *testing.T) {
+	const header = 0x7d
+	err := RegisterScheme(header, func() asymmetric.PublicKey { return new(ed25519PublicKey) })
+	if err != nil {
+		t.Fatalf("register scheme failed, err= %v", err)
+	}
+	defer func() {
+		schemesLock.Lock()
+		delete(schemes, header)
+		schemesLock.Unlock()
+		batchLock.Lock()
+		delete(batchVerifiers, header)
+		batchLock.Unlock()
+	}()
+	//a verifier returning too few results is not trusted
+	err = RegisterBatchVerifier(header, func(keys []asymmetric.PublicKey, hashes [][]byte, sigs [][]byte) []bool {
+		return []bool{true}
+	})
+	if err != nil {
+		t.Fatalf("register batch verifier failed, err= %v", err)
+	}
+	checks := fakeSigChecks(t, header, 4)
+	checks[2].Sig = checks[1].Sig
+	c := NewSigCache(100)
+	for i, res := range c.VerifyBatch(checks, 0) {
+		if res != (i != 2) {
+			t.Errorf("check %d should be %v", i, !res)
+		}
+	}
+	if stats := c.Stats(); stats.Entries != 3 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+}
+
+func TestPreverifyUnit(t *testing.T) {
+	unit := structure.Unit{}
+	h := unit.GetHashToSign()
+	for i := 0; i < 3; i++ {
+		pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+		unit.Authors = append(unit.Authors, &structure.Author{
+			Definition:    append([]byte{SchemeEd25519}, pub...),
+			Authentifiers: ed25519.Sign(priv, h),
+		})
+	}
+	DefaultSigCache().Clear()
+	//the VM preverifies the unit of its context
+	lvm := NewLispVM(vm.Context{TxUnit: unit}, vm.Config{Mode: vm.VMModeContract})
+	_, err := lvm.vm.Eval([]byte(`
+	(each
+		(setq pk (getPK 1))
+		(verify pk (getCurUnitHashToSign) (getSig pk))
+	)`))
+	if err != nil {
+		t.Errorf("verify failed, err= %v", err)
+	}
+	if stats := DefaultSigCache().Stats(); stats.Hits != 1 || stats.Misses != 3 || stats.Entries != 3 {
+		t.Errorf("verify should hit the cache %+v", stats)
+	}
+	//the same unit is preverified once
+	lvm.SetEnv(vm.Context{TxUnit: unit, TxMsgIndex: 1}, vm.Config{Mode: vm.VMModeContract})
+	if stats := DefaultSigCache().Stats(); stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("unit should not be preverified again %+v", stats)
+	}
+	//the host adds the checks it knows
+	extra := fakeSigChecks(t, SchemeEd25519, 2)
+	PreverifyUnit(&unit, 2, extra...)
+	if stats := DefaultSigCache().Stats(); stats.Hits != 4 || stats.Misses != 5 || stats.Entries != 5 {
+		t.Errorf("extra checks should be cached %+v", stats)
+	}
+}
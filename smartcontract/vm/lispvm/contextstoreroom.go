@@ -789,7 +789,7 @@ func (lispvm *LispVM) getCurPrevOutParam(t []lisp.Token, p *lisp.Lisp) (lisp.Tok
 			return lisp.Token{Kind: lisp.String, Text: paramsvalue}, nil
 		}
 	}
-	return lisp.None, errors.New("getCurPrevOutParam failed ")
+	return lisp.None, lisp.NewCondition(CodeParamMissing, x)
 }
 
 //getCurPrevOutParamList returns the list of paramValues in the current PrevOut
@@ -827,7 +827,7 @@ func (lispvm *LispVM) getCurPrevOutParamList(t []lisp.Token, p *lisp.Lisp) (lisp
 			return lisp.Token{Kind: lisp.List, Text: paramList}, nil
 		}
 	}
-	return lisp.None, errors.New("getCurPrevOutParam failed ")
+	return lisp.None, lisp.NewCondition(CodeParamMissing, x)
 }
 
 //getCurPrevOutAmount returns the amount in current PrevOut
@@ -869,7 +869,7 @@ func (lispvm *LispVM) getCurInputParam(t []lisp.Token, p *lisp.Lisp) (lisp.Token
 			return lisp.Token{Kind: lisp.String, Text: paramsvale}, nil
 		}
 	}
-	return lisp.None, errors.New("getCurInputParam failed")
+	return lisp.None, lisp.NewCondition(CodeParamMissing, x)
 }
 
 //hasCurInputParam is to determine whether the current Input ParamValue exists
@@ -17,14 +17,18 @@ package lispvm
 import (
 	"errors"
 
-	"github.com/SHDMT/crypto/bliss"
-	"github.com/SHDMT/crypto/secp256k1"
 	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
 	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
-	"github.com/SHDMT/gravity/infrastructure/log"
 	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
 )
 
+var (
+	//ErrSignerIndex is returned when the signer indexes don't match the keys and signatures
+	ErrSignerIndex = errors.New("invalid signer index")
+	//ErrSignerOrder is returned when the signer indexes are not in key order
+	ErrSignerOrder = errors.New("signers are not in key order")
+)
+
 //verify returns single signature result
 func (lispvm *LispVM) verify(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
 	if len(t) != 3 {
@@ -35,26 +39,9 @@ func (lispvm *LispVM) verify(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
 	if err != nil {
 		return lisp.None, err
 	}
-	if x.Kind != lisp.String {
-		return lisp.None, errors.New("public key is not string")
-	}
-	if len(x.Text.(string)) == 0 {
-		return lisp.None, errors.New("public key is empty")
-	}
-
-	var pubKey asymmetric.PublicKey
-	PK := []byte(x.Text.(string))
-	PKHeader := PK[0]
-	PKBody := PK[1:]
-	log.Debugf(" verify pubkey head : %x \n", PKHeader)
-	if PKHeader == 0 { //第一字节为0表示普通签名
-		pubKey = new(secp256k1.PublicKey)
-	} else {
-		pubKey = new(bliss.PublicKey)
-	}
-	err = pubKey.UnmarshalP(PKBody)
+	pubKey, err := parsePublicKeyToken(x)
 	if err != nil {
-		return lisp.None, errors.New("unmarshal public key failed")
+		return lisp.None, err
 	}
 	//get content hash
 	y, err := p.Exec(t[1])
@@ -81,13 +68,29 @@ func (lispvm *LispVM) verify(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
 	}
 	contSign := ([]byte(z.Text.(string)))
 	//verify
-	res := pubKey.Verify(ConHash, contSign)
+	res := sigCache.Verify([]byte(x.Text.(string)), pubKey, ConHash, contSign)
 	if !res {
-		return lisp.False, errors.New("Verify sign failed")
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, x)
 	}
 	return lisp.True, nil
 }
 
+//pkToAddress returns the author address of public key
+func (lispvm *LispVM) pkToAddress(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 1 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	x, err := p.Exec(t[0])
+	if err != nil {
+		return lisp.None, err
+	}
+	if _, err := parsePublicKeyToken(x); err != nil {
+		return lisp.None, err
+	}
+	addr := addressOf([]byte(x.Text.(string)))
+	return lisp.Token{Kind: lisp.String, Text: string(addr)}, nil
+}
+
 //hash returns hash of content
 func (lispvm *LispVM) hash(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
 	if len(t) != 1 {
@@ -130,31 +133,18 @@ func (lispvm *LispVM) verifyMultiSign(t []lisp.Token, p *lisp.Lisp) (lisp.Token,
 		return lisp.None, lisp.ErrFitType
 	}
 	pubKeys := make([]asymmetric.PublicKey, 0)
+	pks := make([][]byte, 0)
 	for _, v := range x.Text.([]lisp.Token) {
 		r, err := p.Exec(v)
 		if err != nil {
 			return lisp.None, err
 		}
-		if r.Kind != lisp.String {
-			return lisp.None, errors.New("public key is not string")
-		}
-		if len(r.Text.(string)) == 0 {
-			return lisp.None, errors.New("public key is empty")
-		}
-		var pubKey asymmetric.PublicKey
-		PK := []byte(r.Text.(string))
-		PKHeader := PK[0]
-		PKBody := PK[1:]
-		if PKHeader == 0 { //第一字节为0表示普通签名
-			pubKey = new(secp256k1.PublicKey)
-		} else {
-			pubKey = new(bliss.PublicKey)
-		}
-		err = pubKey.UnmarshalP(PKBody)
+		pubKey, err := parsePublicKeyToken(r)
 		if err != nil {
-			return lisp.None, errors.New("unmarshal pubKey failed")
+			return lisp.None, err
 		}
 		pubKeys = append(pubKeys, pubKey)
+		pks = append(pks, []byte(r.Text.(string)))
 	}
 	//get content hash
 	y, err := p.Exec(t[1])
@@ -194,10 +184,11 @@ func (lispvm *LispVM) verifyMultiSign(t []lisp.Token, p *lisp.Lisp) (lisp.Token,
 	//verify signature. if valid signatures' number is more than threshold,return true
 	for _, sig := range sigs {
 		for i, pubKey := range pubKeys {
-			res := pubKey.Verify(ConHash, sig)
+			res := sigCache.Verify(pks[i], pubKey, ConHash, sig)
 			if res {
 				n--
 				pubKeys = append(pubKeys[:i], pubKeys[i+1:]...)
+				pks = append(pks[:i], pks[i+1:]...)
 				break
 			}
 		}
@@ -208,6 +199,99 @@ func (lispvm *LispVM) verifyMultiSign(t []lisp.Token, p *lisp.Lisp) (lisp.Token,
 	return lisp.False, nil
 }
 
+//signerIndexes returns the key indexes of the signers given as a bitmap or a list of indexes
+//bit i of the bitmap, the (i%8)-th lowest bit of byte i/8, marks key i
+func signerIndexes(x lisp.Token, n int, ordered bool) ([]int, error) {
+	indexes := make([]int, 0)
+	switch x.Kind {
+	case lisp.String:
+		bitmap := []byte(x.Text.(string))
+		if len(bitmap) > (n+7)/8 {
+			return nil, ErrSignerIndex
+		}
+		for i := 0; i < len(bitmap)*8; i++ {
+			if bitmap[i/8]>>uint(i%8)&1 == 0 {
+				continue
+			}
+			if i >= n {
+				return nil, ErrSignerIndex
+			}
+			indexes = append(indexes, i)
+		}
+	case lisp.List:
+		used := make(map[int]bool)
+		for _, v := range x.Text.([]lisp.Token) {
+			if v.Kind != lisp.Int {
+				return nil, lisp.ErrFitType
+			}
+			i := v.Text.(int64)
+			if i < 0 || i >= int64(n) || used[int(i)] {
+				return nil, ErrSignerIndex
+			}
+			if ordered && len(indexes) > 0 && int(i) < indexes[len(indexes)-1] {
+				return nil, ErrSignerOrder
+			}
+			used[int(i)] = true
+			indexes = append(indexes, int(i))
+		}
+	default:
+		return nil, lisp.ErrFitType
+	}
+	return indexes, nil
+}
+
+//verifyMultiSignIndexed verifies the j-th signature with the key of the j-th signer index only
+//and returns the list of the indexes of keys which signed
+//(verifyMultiSignIndexed pks hash sigs signers [ordered])
+//signers is a bitmap or a list of indexes, a bitmap is always in key order
+//when ordered is true a list of indexes must be ascending
+func (lispvm *LispVM) verifyMultiSignIndexed(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 4 && len(t) != 5 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	args := make([]lisp.Token, len(t))
+	for i, v := range t {
+		x, err := p.Exec(v)
+		if err != nil {
+			return lisp.None, err
+		}
+		args[i] = x
+	}
+	keys, content, sigs := args[0], args[1], args[2]
+	if keys.Kind != lisp.List || sigs.Kind != lisp.List || content.Kind != lisp.String {
+		return lisp.None, lisp.ErrFitType
+	}
+	if len(content.Text.(string)) == 0 {
+		return lisp.None, errors.New("contents is empty")
+	}
+	ordered := len(args) == 5 && args[4].Bool()
+	pks := keys.Text.([]lisp.Token)
+	indexes, err := signerIndexes(args[3], len(pks), ordered)
+	if err != nil {
+		return lisp.None, err
+	}
+	if len(indexes) != len(sigs.Text.([]lisp.Token)) {
+		return lisp.None, ErrSignerIndex
+	}
+	ConHash := []byte(content.Text.(string))
+	signers := make([]lisp.Token, 0, len(indexes))
+	for j, sig := range sigs.Text.([]lisp.Token) {
+		i := indexes[j]
+		pubKey, err := parsePublicKeyToken(pks[i])
+		if err != nil {
+			return lisp.None, err
+		}
+		if sig.Kind != lisp.String {
+			return lisp.None, errors.New("Sig is not string")
+		}
+		if !sigCache.Verify([]byte(pks[i].Text.(string)), pubKey, ConHash, []byte(sig.Text.(string))) {
+			return lisp.None, lisp.NewCondition(CodeSignatureInvalid, lisp.Token{Kind: lisp.Int, Text: int64(i)})
+		}
+		signers = append(signers, lisp.Token{Kind: lisp.Int, Text: int64(i)})
+	}
+	return lisp.Token{Kind: lisp.List, Text: signers}, nil
+}
+
 //countBytes returns bytes of content
 func (lispvm *LispVM) countBytes(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
 	var bytesLen int64
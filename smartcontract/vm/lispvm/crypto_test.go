@@ -15,13 +15,16 @@
 package lispvm
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/hex"
 	"testing"
 
 	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
 	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric/bliss"
 	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric/ec/secp256k1"
 	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/consensus/structure"
 	"github.com/SHDMT/gravity/platform/smartcontract/vm"
 	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
 )
@@ -543,3 +546,256 @@ func TestLispVM_countBytes(t *testing.T) {
 	}
 
 }
+
+//verifyVector runs verify with a header prefixed public key and hex encoded vector
+func verifyVector(lvm *LispVM, header byte, pk, msg, sign string) (lisp.Token, error) {
+	pkBody, _ := hex.DecodeString(pk)
+	content, _ := hex.DecodeString(msg)
+	sig, _ := hex.DecodeString(sign)
+	tks := []lisp.Token{
+		{Kind: lisp.String, Text: string(append([]byte{header}, pkBody...))},
+		{Kind: lisp.String, Text: string(content)},
+		{Kind: lisp.String, Text: string(sig)},
+	}
+	return lvm.verify(tks, lvm.vm)
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	//RFC 8032 test 2
+	pk := "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c"
+	sig := "92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da" +
+		"085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00"
+	ret, err := verifyVector(lvm, SchemeEd25519, pk, "72", sig)
+	if err != nil || !ret.Bool() {
+		t.Errorf("ed25519 verify failed,err= %v", err)
+	}
+	//wrong content
+	_, err = verifyVector(lvm, SchemeEd25519, pk, "73", sig)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("ed25519 verify should fail,err= %v", err)
+	}
+	//truncated public key
+	_, err = verifyVector(lvm, SchemeEd25519, pk[:62], "72", sig)
+	if lisp.CodeOf(err) != CodePublicKeyInvalid {
+		t.Errorf("ed25519 public key should be invalid,err= %v", err)
+	}
+}
+
+func TestVerifySchnorr(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	//BIP-340 test vectors 0 and 1
+	vectors := []struct {
+		pk, msg, sig string
+	}{
+		{
+			"f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca8215" +
+				"25f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+		},
+		{
+			"dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			"243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			"6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de3341" +
+				"8906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+		},
+	}
+	for i, v := range vectors {
+		ret, err := verifyVector(lvm, SchemeSchnorr, v.pk, v.msg, v.sig)
+		if err != nil || !ret.Bool() {
+			t.Errorf("schnorr vector %d verify failed,err= %v", i, err)
+		}
+	}
+	//signature of another message
+	_, err := verifyVector(lvm, SchemeSchnorr, vectors[1].pk, vectors[0].msg, vectors[1].sig)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("schnorr verify should fail,err= %v", err)
+	}
+	//BIP-340 test vector 5, public key not on the curve
+	_, err = verifyVector(lvm, SchemeSchnorr, "eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34",
+		vectors[1].msg, vectors[1].sig)
+	if lisp.CodeOf(err) != CodePublicKeyInvalid {
+		t.Errorf("schnorr public key should be invalid,err= %v", err)
+	}
+}
+
+func TestDigests(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeRestrict})
+	vectors := map[string]string{
+		"sha256":     "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha256d":    "4f8b42c22dd3729b519ba6f68d2da7cc5b2d606d05daed5ad5128cc03e6c6358",
+		"ripemd160":  "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc",
+		"hash160":    "bb1be98c142444d7a56aa3981c3942a978e4dc33",
+		"keccak256":  "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+		"sha3_256":   "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+		"blake2b256": "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319",
+		"blake2b512": "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1" +
+			"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+	}
+	for name, want := range vectors {
+		//hex form
+		ret, err := lvm.vm.Eval([]byte(`(` + name + ` "abc" 1)`))
+		if err != nil || ret.Kind != lisp.String || ret.Text.(string) != want {
+			t.Errorf("%s hex digest failed,ret= %v,err= %v", name, ret, err)
+		}
+		//raw form
+		ret, err = lvm.vm.Eval([]byte(`(` + name + ` "abc")`))
+		if err != nil || ret.Kind != lisp.String || hex.EncodeToString([]byte(ret.Text.(string))) != want {
+			t.Errorf("%s raw digest failed,err= %v", name, err)
+		}
+	}
+	//digest of empty content
+	ret, err := lvm.vm.Eval([]byte(`(sha256 "" 1)`))
+	if err != nil || ret.Text.(string) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("sha256 of empty content failed,ret= %v,err= %v", ret, err)
+	}
+	//content is not string
+	_, err = lvm.vm.Eval([]byte(`(keccak256 (+ 1 2))`))
+	if err == nil {
+		t.Errorf("keccak256 of int should fail")
+	}
+	//parameter number invalid
+	_, err = lvm.vm.Eval([]byte(`(hash160 "a" 1 2)`))
+	if err == nil {
+		t.Errorf("hash160 with 3 parameters should fail")
+	}
+}
+
+func TestPkToAddress(t *testing.T) {
+	priK, err := secp256k1.NewCipherSuite().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate key failed")
+	}
+	body, _ := priK.Public().MarshalP()
+	blissPriK, err := bliss.NewCipherSuite().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate bliss key failed")
+	}
+	blissBody, _ := blissPriK.Public().MarshalP()
+
+	keys := map[string][]byte{
+		"secp256k1": append([]byte{SchemeSecp256k1}, body...),
+		"bliss":     append([]byte{SchemeBliss}, blissBody...),
+	}
+	for name, pk := range keys {
+		addr := hash.Sum256(pk[1:])
+		lvm := NewLispVM(vm.Context{TxUnit: structure.Unit{
+			Authors: []*structure.Author{{Address: addr, Definition: pk}},
+		}}, vm.Config{Mode: vm.VMModeContract})
+		//the address of the key equals the address of the author using it
+		ret, err := lvm.vm.Eval([]byte(`(= (pkToAddress (getPKByAddr (getAuthorAddr 0))) (getAuthorAddr 0))`))
+		if err != nil || !ret.Bool() {
+			t.Errorf("%s pkToAddress failed,err= %v", name, err)
+		}
+		ret, err = lvm.pkToAddress([]lisp.Token{str(pk)}, lvm.vm)
+		if err != nil || ret.Text.(string) != string(addr) {
+			t.Errorf("%s pkToAddress returns wrong address,err= %v", name, err)
+		}
+		if a, err := AddressOf(pk); err != nil || !a.IsEqual(addr) {
+			t.Errorf("%s AddressOf returns wrong address,err= %v", name, err)
+		}
+	}
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+
+	//known addresses, the Ed25519 and Schnorr keys of the same body have different addresses
+	vectors := []struct {
+		header byte
+		body   string
+		addr   string
+	}{
+		{SchemeSecp256k1, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			"0f715baf5d4c2ed329785cef29e562f73488c8a2bb9dbc5700b361d54b9b0554"},
+		{SchemeEd25519, "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			"156d00348525b761f87eb06fc3204de36c338ffaac19b52906e8b916ee68da98"},
+		{SchemeSchnorr, "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			"8de38f5c6fbfce6ca935012cc82ed5f4dc677d163f27c1fc25946516956f8d9b"},
+		{SchemeEd25519, "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			"13a9521ee5d9b5967ab1515510f1b8c675a9a3ae63c5bb38925f6539a41d409e"},
+	}
+	for _, v := range vectors {
+		b, _ := hex.DecodeString(v.body)
+		pk := append([]byte{v.header}, b...)
+		ret, err := lvm.pkToAddress([]lisp.Token{str(pk)}, lvm.vm)
+		if err != nil || hex.EncodeToString([]byte(ret.Text.(string))) != v.addr {
+			t.Errorf("pkToAddress of %x returns wrong address,err= %v", pk, err)
+		}
+	}
+
+	_, err = lvm.pkToAddress([]lisp.Token{str(append([]byte{7}, body...))}, lvm.vm)
+	if lisp.CodeOf(err) != CodeUnknownScheme {
+		t.Errorf("pkToAddress of unknown scheme should fail,err= %v", err)
+	}
+	_, err = lvm.pkToAddress([]lisp.Token{{Kind: lisp.Int, Text: int64(1)}}, lvm.vm)
+	if err == nil {
+		t.Errorf("pkToAddress of int should fail")
+	}
+}
+
+func TestVerifyMultiSignIndexed(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	content := hash.Sum256([]byte("testcontent"))
+	pks := make([]lisp.Token, 3)
+	sigs := make([]lisp.Token, 3)
+	for i := range pks {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal("generate key failed")
+		}
+		pks[i] = str(append([]byte{SchemeEd25519}, pub...))
+		sigs[i] = str(ed25519.Sign(priv, content))
+	}
+	fold := func(tks ...lisp.Token) lisp.Token {
+		return lisp.Token{Kind: lisp.Fold, Text: tks}
+	}
+	index := func(i int64) lisp.Token {
+		return lisp.Token{Kind: lisp.Int, Text: i}
+	}
+	signed := func(ret lisp.Token, want ...int64) bool {
+		if ret.Kind != lisp.List || len(ret.Text.([]lisp.Token)) != len(want) {
+			return false
+		}
+		for i, v := range ret.Text.([]lisp.Token) {
+			if v.Text.(int64) != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+	keys := fold(pks...)
+
+	//bitmap of key 0 and 2
+	ret, err := lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[0], sigs[2]), str([]byte{0x05})}, lvm.vm)
+	if err != nil || !signed(ret, 0, 2) {
+		t.Errorf("verifyMultiSignIndexed with bitmap failed,ret= %v,err= %v", ret, err)
+	}
+	//list of indexes not in key order
+	ret, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[2], sigs[0]), fold(index(2), index(0))}, lvm.vm)
+	if err != nil || !signed(ret, 2, 0) {
+		t.Errorf("verifyMultiSignIndexed with indexes failed,ret= %v,err= %v", ret, err)
+	}
+	_, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[2], sigs[0]), fold(index(2), index(0)), lisp.True}, lvm.vm)
+	if err != ErrSignerOrder {
+		t.Errorf("verifyMultiSignIndexed should require key order,err= %v", err)
+	}
+	//signature doesn't match the key of its index
+	_, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[0], sigs[1]), str([]byte{0x05})}, lvm.vm)
+	if c := lisp.ConditionOf(err); c.Code != CodeSignatureInvalid || c.Data.Text.(int64) != 2 {
+		t.Errorf("verifyMultiSignIndexed should report the key 2,err= %v", err)
+	}
+	//duplicate index
+	_, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[0], sigs[0]), fold(index(0), index(0))}, lvm.vm)
+	if err != ErrSignerIndex {
+		t.Errorf("verifyMultiSignIndexed should refuse duplicate index,err= %v", err)
+	}
+	//bitmap marks a key out of range
+	_, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[0], sigs[0]), str([]byte{0x09})}, lvm.vm)
+	if err != ErrSignerIndex {
+		t.Errorf("verifyMultiSignIndexed should refuse index out of range,err= %v", err)
+	}
+	//number of signers doesn't match signatures
+	_, err = lvm.verifyMultiSignIndexed([]lisp.Token{keys, str(content), fold(sigs[0]), str([]byte{0x05})}, lvm.vm)
+	if err != ErrSignerIndex {
+		t.Errorf("verifyMultiSignIndexed should refuse signature count mismatch,err= %v", err)
+	}
+}
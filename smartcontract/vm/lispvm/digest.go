@@ -0,0 +1,92 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"encoding/hex"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+)
+
+//digests are the hash functions exposed to contracts, the key is the name of the builtin
+//every builtin returns the raw digest, or its hex form when the optional second argument is true
+//(sha256 content) (sha256 content 1)
+var digests = map[string]func([]byte) []byte{
+	"sha256": func(b []byte) []byte {
+		return hash.Sum256(b)
+	},
+	"sha256d": func(b []byte) []byte {
+		return hash.Sum256(hash.Sum256(b))
+	},
+	"ripemd160": ripemd160Sum,
+	"hash160": func(b []byte) []byte {
+		return ripemd160Sum(hash.Sum256(b))
+	},
+	"keccak256": func(b []byte) []byte {
+		h := sha3.NewLegacyKeccak256()
+		h.Write(b)
+		return h.Sum(nil)
+	},
+	"sha3_256": func(b []byte) []byte {
+		h := sha3.Sum256(b)
+		return h[:]
+	},
+	"blake2b256": func(b []byte) []byte {
+		h := blake2b.Sum256(b)
+		return h[:]
+	},
+	"blake2b512": func(b []byte) []byte {
+		h := blake2b.Sum512(b)
+		return h[:]
+	},
+}
+
+//ripemd160Sum returns the ripemd160 digest of b
+func ripemd160Sum(b []byte) []byte {
+	h := ripemd160.New()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+//digest returns the builtin of the hash function
+func (lispvm *LispVM) digest(sum func([]byte) []byte) func([]lisp.Token, *lisp.Lisp) (lisp.Token, error) {
+	return func(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+		if len(t) != 1 && len(t) != 2 {
+			return lisp.None, lisp.ErrParaNum
+		}
+		x, err := p.Exec(t[0])
+		if err != nil {
+			return lisp.None, err
+		}
+		if x.Kind != lisp.String {
+			return lisp.None, lisp.ErrFitType
+		}
+		d := sum([]byte(x.Text.(string)))
+		if len(t) == 2 {
+			h, err := p.Exec(t[1])
+			if err != nil {
+				return lisp.None, err
+			}
+			if h.Bool() {
+				return lisp.Token{Kind: lisp.String, Text: hex.EncodeToString(d)}, nil
+			}
+		}
+		return lisp.Token{Kind: lisp.String, Text: string(d)}, nil
+	}
+}
@@ -0,0 +1,52 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+//ErrEd25519KeySize is returned when an ed25519 public key is not 32 bytes
+var ErrEd25519KeySize = errors.New("ed25519 public key must be 32 bytes")
+
+//ed25519PublicKey is an RFC 8032 ed25519 public key
+//the content passed to verify is signed as is, without hashing it again
+type ed25519PublicKey []byte
+
+//Verify checks signature of hash
+func (pk *ed25519PublicKey) Verify(hash []byte, signature []byte) bool {
+	if len(*pk) != ed25519.PublicKeySize || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(*pk), hash, signature)
+}
+
+//MarshalP returns the 32 bytes public key
+func (pk *ed25519PublicKey) MarshalP() ([]byte, error) {
+	if len(*pk) != ed25519.PublicKeySize {
+		return nil, ErrEd25519KeySize
+	}
+	return append([]byte(nil), *pk...), nil
+}
+
+//UnmarshalP parses a 32 bytes public key
+func (pk *ed25519PublicKey) UnmarshalP(data []byte) error {
+	if len(data) != ed25519.PublicKeySize {
+		return ErrEd25519KeySize
+	}
+	*pk = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,256 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//The followings define the parameters of the hash-based one-time signatures
+//all of them sign a 32 bytes content hash and use sha256 as the one-way function
+const (
+	//hashSize is the size of every hash value
+	hashSize = sha256.Size
+	//wotsW is the Winternitz parameter, every chain signs 4 bits
+	wotsW = 16
+	//wotsLen1 is the number of chains signing the content hash
+	wotsLen1 = hashSize * 8 / 4
+	//wotsLen2 is the number of chains signing the checksum
+	wotsLen2 = 3
+	//wotsLen is the number of chains of a WOTS key
+	wotsLen = wotsLen1 + wotsLen2
+	//WOTSSignatureSize is the size of a WOTS signature
+	WOTSSignatureSize = wotsLen * hashSize
+	//LamportPublicKeySize is the size of a lamport public key, two hashes for each bit
+	LamportPublicKeySize = 2 * 8 * hashSize * hashSize
+	//LamportSignatureSize is the size of a lamport signature, one preimage for each bit
+	LamportSignatureSize = 8 * hashSize * hashSize
+)
+
+//The followings define the steps charged for the hashes of a verification, one step for each hash
+const (
+	//wotsSteps covers all chains walked to their ends and the compression of the ends
+	wotsSteps = wotsLen*(wotsW-1) + 1
+	//lamportSteps covers the preimage of each bit
+	lamportSteps = 8 * hashSize
+)
+
+//ErrOneTimeSignature is returned when the sizes of a one-time signature are wrong
+var ErrOneTimeSignature = errors.New("invalid one-time signature")
+
+//wotsDigits returns the base-16 digits of the content hash followed by the checksum digits
+func wotsDigits(h []byte) []int {
+	digits := make([]int, 0, wotsLen)
+	for _, b := range h {
+		digits = append(digits, int(b>>4), int(b&0x0f))
+	}
+	checksum := 0
+	for _, d := range digits {
+		checksum += wotsW - 1 - d
+	}
+	for i := wotsLen2 - 1; i >= 0; i-- {
+		digits = append(digits, (checksum>>(uint(i)*4))&0x0f)
+	}
+	return digits
+}
+
+//wotsChain applies the one-way function steps times
+func wotsChain(x []byte, steps int) []byte {
+	for i := 0; i < steps; i++ {
+		h := sha256.Sum256(x)
+		x = h[:]
+	}
+	return x
+}
+
+//WOTSPublicKey recovers the compressed WOTS public key from a signature of the content hash
+//the compressed public key is the hash of the ends of all chains
+func WOTSPublicKey(h []byte, sig []byte) ([]byte, error) {
+	if len(h) != hashSize || len(sig) != WOTSSignatureSize {
+		return nil, ErrOneTimeSignature
+	}
+	ends := sha256.New()
+	for i, d := range wotsDigits(h) {
+		ends.Write(wotsChain(sig[i*hashSize:(i+1)*hashSize], wotsW-1-d))
+	}
+	return ends.Sum(nil), nil
+}
+
+//VerifyLamport checks a lamport signature of the content hash
+//the i-th 64 bytes of the public key are the hashes of the two preimages of the i-th bit
+func VerifyLamport(pk []byte, h []byte, sig []byte) bool {
+	if len(pk) != LamportPublicKeySize || len(h) != hashSize || len(sig) != LamportSignatureSize {
+		return false
+	}
+	for i := 0; i < 8*hashSize; i++ {
+		bit := int(h[i/8]>>(7-uint(i%8))) & 1
+		image := sha256.Sum256(sig[i*hashSize : (i+1)*hashSize])
+		off := (2*i + bit) * hashSize
+		if string(image[:]) != string(pk[off:off+hashSize]) {
+			return false
+		}
+	}
+	return true
+}
+
+//MerkleLeaf returns the leaf of a compressed one-time public key in the key tree
+func MerkleLeaf(pk []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, pk...))
+	return h[:]
+}
+
+//MerkleNode returns the parent of two nodes in the key tree
+func MerkleNode(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+2*hashSize)
+	buf = append(buf, 1)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+//merkleRoot folds the authentication path of the leaf at index
+//the path is the concatenation of the sibling nodes from the leaf up to the root
+func merkleRoot(leaf []byte, index int64, path []byte) ([]byte, error) {
+	depth := len(path) / hashSize
+	if len(path)%hashSize != 0 || index < 0 {
+		return nil, ErrOneTimeSignature
+	}
+	//the index must address a leaf of the tree
+	if depth < 63 && index >= int64(1)<<uint(depth) {
+		return nil, ErrOneTimeSignature
+	}
+	node := leaf
+	for i := 0; i < len(path); i += hashSize {
+		if index&1 == 0 {
+			node = MerkleNode(node, path[i:i+hashSize])
+		} else {
+			node = MerkleNode(path[i:i+hashSize], node)
+		}
+		index >>= 1
+	}
+	return node, nil
+}
+
+//stringArgs executes the arguments which must be non-empty strings
+func stringArgs(t []lisp.Token, p *lisp.Lisp) ([]lisp.Token, error) {
+	args := make([]lisp.Token, len(t))
+	for i, v := range t {
+		x, err := p.Exec(v)
+		if err != nil {
+			return nil, err
+		}
+		if x.Kind != lisp.String {
+			return nil, lisp.ErrFitType
+		}
+		if len(x.Text.(string)) == 0 {
+			return nil, errors.New("argument is empty")
+		}
+		args[i] = x
+	}
+	return args, nil
+}
+
+//verifyWOTS checks a WOTS signature against a compressed public key
+//(verifyWOTS pk hash sig)
+func (lispvm *LispVM) verifyWOTS(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 3 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	args, err := stringArgs(t, p)
+	if err != nil {
+		return lisp.None, err
+	}
+	if len(args[0].Text.(string)) != hashSize {
+		return lisp.None, lisp.NewCondition(CodePublicKeyInvalid, args[0])
+	}
+	if err := charge(p, wotsSteps); err != nil {
+		return lisp.None, err
+	}
+	pk, err := WOTSPublicKey([]byte(args[1].Text.(string)), []byte(args[2].Text.(string)))
+	if err != nil || string(pk) != args[0].Text.(string) {
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, args[0])
+	}
+	return lisp.True, nil
+}
+
+//verifyLamport checks a lamport signature
+//(verifyLamport pk hash sig)
+func (lispvm *LispVM) verifyLamport(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 3 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	args, err := stringArgs(t, p)
+	if err != nil {
+		return lisp.None, err
+	}
+	if len(args[0].Text.(string)) != LamportPublicKeySize {
+		return lisp.None, lisp.NewCondition(CodePublicKeyInvalid, args[0])
+	}
+	if err := charge(p, lamportSteps); err != nil {
+		return lisp.None, err
+	}
+	if !VerifyLamport([]byte(args[0].Text.(string)), []byte(args[1].Text.(string)), []byte(args[2].Text.(string))) {
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, args[0])
+	}
+	return lisp.True, nil
+}
+
+//verifyXMSS checks a WOTS signature made by the leaf at index of a key tree
+//(verifyXMSS root index hash sig path)
+func (lispvm *LispVM) verifyXMSS(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 5 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	index, err := p.Exec(t[1])
+	if err != nil {
+		return lisp.None, err
+	}
+	if index.Kind != lisp.Int {
+		return lisp.None, lisp.ErrFitType
+	}
+	args, err := stringArgs([]lisp.Token{t[0], t[2], t[3]}, p)
+	if err != nil {
+		return lisp.None, err
+	}
+	root := args[0]
+	if len(root.Text.(string)) != hashSize {
+		return lisp.None, lisp.NewCondition(CodePublicKeyInvalid, root)
+	}
+	//the path of a tree of a single key is empty
+	path, err := p.Exec(t[4])
+	if err != nil {
+		return lisp.None, err
+	}
+	if path.Kind != lisp.String {
+		return lisp.None, lisp.ErrFitType
+	}
+	//the leaf and every level of the tree are one more hash each
+	if err := charge(p, wotsSteps+1+len(path.Text.(string))/hashSize); err != nil {
+		return lisp.None, err
+	}
+	pk, err := WOTSPublicKey([]byte(args[1].Text.(string)), []byte(args[2].Text.(string)))
+	if err != nil {
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, root)
+	}
+	node, err := merkleRoot(MerkleLeaf(pk), index.Text.(int64), []byte(path.Text.(string)))
+	if err != nil || string(node) != root.Text.(string) {
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, root)
+	}
+	return lisp.True, nil
+}
@@ -0,0 +1,172 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//wotsKey generates a WOTS private key and its compressed public key
+func wotsKey(t *testing.T) ([]byte, []byte) {
+	priK := make([]byte, WOTSSignatureSize)
+	if _, err := rand.Read(priK); err != nil {
+		t.Fatal("generate wots key failed")
+	}
+	ends := sha256.New()
+	for i := 0; i < wotsLen; i++ {
+		ends.Write(wotsChain(priK[i*hashSize:(i+1)*hashSize], wotsW-1))
+	}
+	return priK, ends.Sum(nil)
+}
+
+//wotsSign signs the content hash with a WOTS private key
+func wotsSign(priK []byte, h []byte) []byte {
+	sig := make([]byte, 0, WOTSSignatureSize)
+	for i, d := range wotsDigits(h) {
+		sig = append(sig, wotsChain(priK[i*hashSize:(i+1)*hashSize], d)...)
+	}
+	return sig
+}
+
+func str(b []byte) lisp.Token {
+	return lisp.Token{Kind: lisp.String, Text: string(b)}
+}
+
+func TestVerifyWOTS(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	priK, pk := wotsKey(t)
+	h := hash.Sum256([]byte("testcontent"))
+	sig := wotsSign(priK, h)
+
+	ret, err := lvm.verifyWOTS([]lisp.Token{str(pk), str(h), str(sig)}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("wots verify failed,err= %v", err)
+	}
+	other := hash.Sum256([]byte("othercontent"))
+	_, err = lvm.verifyWOTS([]lisp.Token{str(pk), str(other), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("wots verify of other content should fail,err= %v", err)
+	}
+	_, err = lvm.verifyWOTS([]lisp.Token{str(pk), str(h), str(sig[1:])}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("wots verify of short signature should fail,err= %v", err)
+	}
+	_, err = lvm.verifyWOTS([]lisp.Token{str(pk[1:]), str(h), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodePublicKeyInvalid {
+		t.Errorf("wots public key should be invalid,err= %v", err)
+	}
+
+	lvm = NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract, MaxStep: wotsSteps - 1})
+	_, err = lvm.verifyWOTS([]lisp.Token{str(pk), str(h), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeStepLimit {
+		t.Errorf("wots verify should exceed the step limit,err= %v", err)
+	}
+}
+
+func TestVerifyLamport(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	priK := make([]byte, LamportPublicKeySize)
+	if _, err := rand.Read(priK); err != nil {
+		t.Fatal("generate lamport key failed")
+	}
+	pk := make([]byte, 0, LamportPublicKeySize)
+	for i := 0; i < len(priK); i += hashSize {
+		image := sha256.Sum256(priK[i : i+hashSize])
+		pk = append(pk, image[:]...)
+	}
+	h := hash.Sum256([]byte("testcontent"))
+	sig := make([]byte, 0, LamportSignatureSize)
+	for i := 0; i < 8*hashSize; i++ {
+		bit := int(h[i/8]>>(7-uint(i%8))) & 1
+		off := (2*i + bit) * hashSize
+		sig = append(sig, priK[off:off+hashSize]...)
+	}
+
+	ret, err := lvm.verifyLamport([]lisp.Token{str(pk), str(h), str(sig)}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("lamport verify failed,err= %v", err)
+	}
+	other := hash.Sum256([]byte("othercontent"))
+	_, err = lvm.verifyLamport([]lisp.Token{str(pk), str(other), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("lamport verify of other content should fail,err= %v", err)
+	}
+	_, err = lvm.verifyLamport([]lisp.Token{str(pk[:hashSize]), str(h), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodePublicKeyInvalid {
+		t.Errorf("lamport public key should be invalid,err= %v", err)
+	}
+
+	lvm = NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract, MaxStep: lamportSteps - 1})
+	_, err = lvm.verifyLamport([]lisp.Token{str(pk), str(h), str(sig)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeStepLimit {
+		t.Errorf("lamport verify should exceed the step limit,err= %v", err)
+	}
+}
+
+func TestVerifyXMSS(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	//a tree of 4 one-time keys
+	priKs := make([][]byte, 4)
+	leaves := make([][]byte, 4)
+	for i := range priKs {
+		var pk []byte
+		priKs[i], pk = wotsKey(t)
+		leaves[i] = MerkleLeaf(pk)
+	}
+	n01 := MerkleNode(leaves[0], leaves[1])
+	n23 := MerkleNode(leaves[2], leaves[3])
+	root := MerkleNode(n01, n23)
+
+	h := hash.Sum256([]byte("testcontent"))
+	sig := wotsSign(priKs[2], h)
+	path := append(append([]byte{}, leaves[3]...), n01...)
+	index := lisp.Token{Kind: lisp.Int, Text: int64(2)}
+
+	ret, err := lvm.verifyXMSS([]lisp.Token{str(root), index, str(h), str(sig), str(path)}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("xmss verify failed,err= %v", err)
+	}
+	//signature of another leaf
+	wrong := lisp.Token{Kind: lisp.Int, Text: int64(3)}
+	_, err = lvm.verifyXMSS([]lisp.Token{str(root), wrong, str(h), str(sig), str(path)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("xmss verify of wrong index should fail,err= %v", err)
+	}
+	//index out of the tree
+	wrong = lisp.Token{Kind: lisp.Int, Text: int64(6)}
+	_, err = lvm.verifyXMSS([]lisp.Token{str(root), wrong, str(h), str(sig), str(path)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("xmss verify of index out of tree should fail,err= %v", err)
+	}
+	//signature made by another key
+	sig = wotsSign(priKs[1], h)
+	_, err = lvm.verifyXMSS([]lisp.Token{str(root), index, str(h), str(sig), str(path)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("xmss verify of other key should fail,err= %v", err)
+	}
+
+	//the leaf and the 2 levels of the tree cost 3 steps more than the wots signature
+	lvm = NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract, MaxStep: wotsSteps + 2})
+	_, err = lvm.verifyXMSS([]lisp.Token{str(root), index, str(h), str(sig), str(path)}, lvm.vm)
+	if lisp.CodeOf(err) != CodeStepLimit {
+		t.Errorf("xmss verify should exceed the step limit,err= %v", err)
+	}
+}
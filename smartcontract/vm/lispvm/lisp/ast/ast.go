@@ -0,0 +1,123 @@
+//Package ast provides the syntax tree of lisp code with position information,
+//a walker over the tree and a canonical printer
+package ast
+
+import (
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp/parser"
+)
+
+//Pos describes a position in the raw code
+//Offset starts from 0, Line and Column start from 1
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+//Node is a node of syntax tree
+//Token is the same Token produced by lisp.Tree, so it can be executed directly
+//List holds the child nodes of a list or a fold list
+//Pos is the start position of the node and End is the position following the node
+type Node struct {
+	Token lisp.Token
+	List  []*Node
+	Pos   Pos
+	End   Pos
+}
+
+//Parse does the lexical analysis and parsing of raw code as lisp.Scan and lisp.Tree
+//output is an array of root of a syntax tree with position information
+func Parse(src []byte) ([]*Node, error) {
+	tkn, offset, err := lisp.ScanOffset(src)
+	if err != nil {
+		return nil, err
+	}
+	lines := lineStarts(src)
+	stack := []*Node{{}}
+	for i, t := range tkn {
+		pos := position(lines, offset[i])
+		if t.Kind != lisp.Operator {
+			end := position(lines, tokenEnd(src, offset[i]))
+			top := stack[len(stack)-1]
+			top.List = append(top.List, &Node{Token: t, Pos: pos, End: end})
+			continue
+		}
+		switch t.Text.(byte) {
+		case '(':
+			stack = append(stack, &Node{Token: lisp.Token{Kind: lisp.List}, Pos: pos})
+		case '[':
+			stack = append(stack, &Node{Token: lisp.Token{Kind: lisp.Fold}, Pos: pos})
+		case ')':
+			if len(stack) < 2 {
+				return nil, lisp.ErrUnquote
+			}
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			n.End = position(lines, offset[i]+1)
+			var children []lisp.Token
+			for _, c := range n.List {
+				children = append(children, c.Token)
+			}
+			n.Token.Text = children
+			top := stack[len(stack)-1]
+			top.List = append(top.List, n)
+		default:
+			return nil, lisp.ErrUnquote
+		}
+	}
+	if len(stack) != 1 {
+		return nil, lisp.ErrUnquote
+	}
+	return stack[0].List, nil
+}
+
+//lineStarts returns the offsets at which each line of raw code starts
+func lineStarts(src []byte) []int {
+	lines := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+//position converts an offset to a position
+func position(lines []int, offset int) Pos {
+	i, j := 0, len(lines)
+	for j-i > 1 {
+		m := (i + j) / 2
+		if lines[m] <= offset {
+			i = m
+		} else {
+			j = m
+		}
+	}
+	return Pos{Offset: offset, Line: i + 1, Column: offset - lines[i] + 1}
+}
+
+//tokenEnd returns the offset following the atom starting at offset
+func tokenEnd(src []byte, offset int) int {
+	i := offset
+	if i < len(src) && src[i] == '"' {
+		for i++; i < len(src) && src[i] != '"'; i++ {
+			if src[i] == '\\' {
+				i++
+			}
+		}
+		return i + 1
+	}
+	if i < len(src) && src[i] == '\'' {
+		for i++; i < len(src) && src[i] != '\''; i++ {
+			if src[i] == '\\' {
+				i++
+			}
+		}
+		return i + 1
+	}
+	for i < len(src) && src[i] != '(' && src[i] != ')' && !parser.IsSpace(src[i]) {
+		i++
+	}
+	return i
+}
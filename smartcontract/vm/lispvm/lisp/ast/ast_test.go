@@ -0,0 +1,107 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+var code = `(define n 1)
+(if (> n 0)
+    '(a "b c")
+    3.5)`
+
+func TestParse(t *testing.T) {
+	nodes, err := Parse([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := lisp.Scan([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := lisp.Tree(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(tree) {
+		t.Fatalf("The number of roots should be %d, but got %d\n", len(tree), len(nodes))
+	}
+	for i := range tree {
+		if !nodes[i].Token.Eq(&tree[i]) {
+			t.Errorf("The token should be %v, but got %v\n", tree[i], nodes[i].Token)
+		}
+	}
+
+	if nodes[1].Pos != (Pos{Offset: 13, Line: 2, Column: 1}) {
+		t.Errorf("The position of if should be 2:1, but got %v\n", nodes[1].Pos)
+	}
+	if nodes[1].End != (Pos{Offset: len(code), Line: 4, Column: 9}) {
+		t.Errorf("The end of if should be 4:9, but got %v\n", nodes[1].End)
+	}
+	fold := nodes[1].List[2]
+	if fold.Token.Kind != lisp.Fold || fold.Pos.Line != 3 || fold.Pos.Column != 5 {
+		t.Errorf("The fold list should be at 3:5, but got %v %v\n", fold.Token.Kind, fold.Pos)
+	}
+	str := fold.List[1]
+	if str.Pos.Column != 9 || str.End.Column != 14 {
+		t.Errorf("The string should span 3:9-3:14, but got %v-%v\n", str.Pos, str.End)
+	}
+
+	_, err = Parse([]byte(`(define n 1`))
+	if err != lisp.ErrUnquote {
+		t.Errorf("Error not checked, list is not closed\n")
+	}
+	_, err = Parse([]byte(`n)`))
+	if err != lisp.ErrUnquote {
+		t.Errorf("Error not checked, list is not opened\n")
+	}
+}
+
+type counter map[lisp.Kind]int
+
+func (c counter) Visit(n *Node) Visitor {
+	if n != nil {
+		c[n.Token.Kind]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	nodes, err := Parse([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := counter{}
+	for _, n := range nodes {
+		Walk(c, n)
+	}
+	if c[lisp.List] != 3 || c[lisp.Fold] != 1 || c[lisp.Label] != 6 || c[lisp.String] != 1 {
+		t.Errorf("Wrong number of visited nodes %v\n", c)
+	}
+
+	labels := 0
+	Inspect(nodes[1], func(n *Node) bool {
+		if n == nil {
+			return false
+		}
+		if n.Token.Kind == lisp.Label {
+			labels++
+		}
+		return n.Token.Kind != lisp.Fold
+	})
+	if labels != 3 {
+		t.Errorf("Children of fold list should be skipped, but got %d labels\n", labels)
+	}
+
+	ints := 0
+	InspectToken(nodes[1].Token, func(t lisp.Token) bool {
+		if t.Kind == lisp.Int {
+			ints++
+		}
+		return true
+	})
+	if ints != 1 {
+		t.Errorf("The number of integers should be 1, but got %d\n", ints)
+	}
+}
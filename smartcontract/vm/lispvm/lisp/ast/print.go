@@ -0,0 +1,99 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//ErrNotPrintable is returned when a token has no code form, such as a function or a macro
+var ErrNotPrintable = errors.New("token can't be printed as code")
+
+//Fprint writes the canonical code of the tokens to w
+//each token is written on its own line, the elements of a list are separated by one space
+//the canonical code parses back to equal tokens, so codes differing only in whitespace print identically
+func Fprint(w io.Writer, tokens ...lisp.Token) error {
+	buf := new(bytes.Buffer)
+	for i, t := range tokens {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		if err := write(buf, t); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//Format returns the canonical code of the tokens
+func Format(tokens ...lisp.Token) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := Fprint(buf, tokens...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//Canonical parses the raw code and returns its canonical code
+//it can be used to normalize the code of a contract before calculating its address
+func Canonical(src []byte) ([]byte, error) {
+	nodes, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]lisp.Token, len(nodes))
+	for i, n := range nodes {
+		tokens[i] = n.Token
+	}
+	buf := new(bytes.Buffer)
+	if err = Fprint(buf, tokens...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func write(buf *bytes.Buffer, t lisp.Token) error {
+	switch t.Kind {
+	case lisp.Int:
+		buf.WriteString(strconv.FormatInt(t.Text.(int64), 10))
+	case lisp.Float:
+		f := t.Text.(float64)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return ErrNotPrintable
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case lisp.String:
+		buf.WriteByte('"')
+		buf.WriteString(t.Text.(string))
+		buf.WriteByte('"')
+	case lisp.Label:
+		buf.WriteString(string(t.Text.(lisp.Name)))
+	case lisp.List, lisp.Fold:
+		if t.Kind == lisp.Fold {
+			buf.WriteByte('\'')
+		}
+		buf.WriteByte('(')
+		for i, c := range t.Text.([]lisp.Token) {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			if err := write(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(')')
+	default:
+		return ErrNotPrintable
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+func TestCanonical(t *testing.T) {
+	a, err := Canonical([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(define n 1)` + "\n" + `(if (> n 0) '(a "b c") 3.5)`
+	if string(a) != want {
+		t.Errorf("The canonical code should be %s, but got %s\n", want, a)
+	}
+
+	b, err := Canonical([]byte("  (define   n\t1 )\r\n\n(if\n(> n 0) '( a \"b c\" )\n  3.50 )  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("Whitespace should not change the canonical code, got %s\n", b)
+	}
+
+	c, err := Canonical(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(c) {
+		t.Errorf("Canonical code should be stable, got %s\n", c)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tokens := []lisp.Token{
+		{Kind: lisp.Int, Text: int64(-12)},
+		{Kind: lisp.Float, Text: float64(2)},
+		{Kind: lisp.Float, Text: float64(1e21)},
+		{Kind: lisp.Float, Text: float64(-0.25)},
+		{Kind: lisp.String, Text: `a \"b\"`},
+		{Kind: lisp.List, Text: []lisp.Token(nil)},
+		{Kind: lisp.Fold, Text: []lisp.Token{{Kind: lisp.Label, Text: lisp.Name("x")}}},
+	}
+	s, err := Format(tokens...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-12\n2.0\n1e+21\n-0.25\n\"a \\\"b\\\"\"\n()\n'(x)"
+	if s != want {
+		t.Errorf("The code should be %q, but got %q\n", want, s)
+	}
+
+	back, err := lisp.Scan([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := lisp.Tree(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tokens {
+		if !tree[i].Eq(&tokens[i]) {
+			t.Errorf("The token should print back to %v, but got %v\n", tokens[i], tree[i])
+		}
+	}
+
+	_, err = Format(lisp.Token{Kind: lisp.Back, Text: lisp.Gfac(nil)})
+	if err != ErrNotPrintable {
+		t.Errorf("Error not checked, a go function can't be printed\n")
+	}
+}
@@ -0,0 +1,50 @@
+package ast
+
+import "github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+
+//Visitor is called by Walk for each node
+//if the returned visitor w is not nil, Walk visits each child of the node with w,
+//followed by a call of w.Visit(nil)
+type Visitor interface {
+	Visit(n *Node) (w Visitor)
+}
+
+//Walk traverses a syntax tree in depth-first order
+func Walk(v Visitor, n *Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, c := range n.List {
+		Walk(v, c)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(n *Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+//Inspect traverses a syntax tree in depth-first order
+//f is called for each node, and the children are visited if f returns true
+//f is called with nil after the children of a node are visited
+func Inspect(n *Node, f func(*Node) bool) {
+	Walk(inspector(f), n)
+}
+
+//InspectToken traverses a lisp.Token tree without position information in depth-first order
+//f is called for each token, and the elements of a list or a fold list are visited if f returns true
+func InspectToken(t lisp.Token, f func(lisp.Token) bool) {
+	if !f(t) {
+		return
+	}
+	if t.Kind == lisp.List || t.Kind == lisp.Fold {
+		for _, c := range t.Text.([]lisp.Token) {
+			InspectToken(c, f)
+		}
+	}
+}
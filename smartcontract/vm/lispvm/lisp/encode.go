@@ -0,0 +1,210 @@
+package lisp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+//EncodingVersion is the version of the binary encoding of Token, which is the first byte of an encoding
+const EncodingVersion = 1
+
+//maxDepth limits the nesting of lists made by Encode and accepted by Decode
+const maxDepth = 256
+
+//The followings define the tags of kinds in the binary encoding
+//they are part of the encoding and must never be changed
+const (
+	tagNull   = 0x00
+	tagInt    = 0x01
+	tagFloat  = 0x02
+	tagString = 0x03
+	tagFold   = 0x04
+	tagList   = 0x05
+	tagLabel  = 0x06
+)
+
+//Encode returns the canonical binary encoding of a Token
+//the encoding is the version byte followed by the encoded value
+//a value is encoded as a tag byte followed by:
+//	Null: nothing
+//	Int: 8 bytes of two's complement in big endian
+//	Float: 8 bytes of IEEE 754 in big endian, -0 is encoded as 0 and NaN is refused
+//	String, Label: 4 bytes of length in big endian and the bytes
+//	List, Fold: 4 bytes of element count in big endian and the encoded elements
+//equal tokens always get the same encoding, Back, Front, Macro and Operator are refused
+//nesting deeper than 256 lists is refused with ErrTooDeep, as Decode would refuse it
+func Encode(t Token) ([]byte, error) {
+	return encode([]byte{EncodingVersion}, t, 0)
+}
+
+func encode(buf []byte, t Token, depth int) ([]byte, error) {
+	var (
+		b   [8]byte
+		err error
+	)
+	if depth > maxDepth {
+		return nil, ErrTooDeep
+	}
+	switch t.Kind {
+	case Null:
+		return append(buf, tagNull), nil
+	case Int:
+		binary.BigEndian.PutUint64(b[:], uint64(t.Text.(int64)))
+		return append(append(buf, tagInt), b[:]...), nil
+	case Float:
+		f := t.Text.(float64)
+		if math.IsNaN(f) {
+			return nil, ErrNoCodec
+		}
+		if f == 0 {
+			f = 0
+		}
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+		return append(append(buf, tagFloat), b[:]...), nil
+	case String:
+		return appendBytes(append(buf, tagString), t.Text.(string)), nil
+	case Label:
+		return appendBytes(append(buf, tagLabel), string(t.Text.(Name))), nil
+	case List, Fold:
+		tag := byte(tagList)
+		if t.Kind == Fold {
+			tag = tagFold
+		}
+		ls := t.Text.([]Token)
+		binary.BigEndian.PutUint32(b[:4], uint32(len(ls)))
+		buf = append(append(buf, tag), b[:4]...)
+		for _, c := range ls {
+			buf, err = encode(buf, c, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, ErrNoCodec
+}
+
+func appendBytes(buf []byte, s string) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(s)))
+	return append(append(buf, b[:]...), s...)
+}
+
+//Decode parses an encoding made by Encode
+//it refuses unknown versions and any input which is not the canonical encoding of a Token,
+//including truncated data, trailing bytes, unknown tags, -0, NaN and nesting deeper than 256 lists
+func Decode(b []byte) (Token, error) {
+	if len(b) == 0 {
+		return None, ErrBadCode
+	}
+	if b[0] != EncodingVersion {
+		return None, ErrVersion
+	}
+	t, n, err := decode(b[1:], 0)
+	if err != nil {
+		return None, err
+	}
+	if n != len(b)-1 {
+		return None, ErrBadCode
+	}
+	return t, nil
+}
+
+func decode(b []byte, depth int) (Token, int, error) {
+	if len(b) == 0 || depth > maxDepth {
+		return None, 0, ErrBadCode
+	}
+	switch b[0] {
+	case tagNull:
+		return None, 1, nil
+	case tagInt:
+		if len(b) < 9 {
+			return None, 0, ErrBadCode
+		}
+		return Token{Kind: Int, Text: int64(binary.BigEndian.Uint64(b[1:9]))}, 9, nil
+	case tagFloat:
+		if len(b) < 9 {
+			return None, 0, ErrBadCode
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(b[1:9]))
+		if math.IsNaN(f) || (f == 0 && math.Signbit(f)) {
+			return None, 0, ErrBadCode
+		}
+		return Token{Kind: Float, Text: f}, 9, nil
+	case tagString, tagLabel:
+		if len(b) < 5 {
+			return None, 0, ErrBadCode
+		}
+		l := binary.BigEndian.Uint32(b[1:5])
+		if uint64(l) > uint64(len(b)-5) {
+			return None, 0, ErrBadCode
+		}
+		s := string(b[5 : 5+l])
+		if b[0] == tagLabel {
+			if l == 0 {
+				return None, 0, ErrBadCode
+			}
+			return Token{Kind: Label, Text: Name(s)}, 5 + int(l), nil
+		}
+		return Token{Kind: String, Text: s}, 5 + int(l), nil
+	case tagList, tagFold:
+		if len(b) < 5 {
+			return None, 0, ErrBadCode
+		}
+		l := binary.BigEndian.Uint32(b[1:5])
+		if uint64(l) > uint64(len(b)-5) {
+			return None, 0, ErrBadCode
+		}
+		var ls []Token
+		if l > 0 {
+			ls = make([]Token, l)
+		}
+		n := 5
+		for i := range ls {
+			c, m, err := decode(b[n:], depth+1)
+			if err != nil {
+				return None, 0, err
+			}
+			ls[i] = c
+			n += m
+		}
+		if b[0] == tagFold {
+			return Token{Kind: Fold, Text: ls}, n, nil
+		}
+		return Token{Kind: List, Text: ls}, n, nil
+	}
+	return None, 0, ErrBadCode
+}
+
+func init() {
+	//implementation of the system function "encode" used to convert a value to its binary encoding in a string
+	Add("encode", func(t []Token, p *Lisp) (Token, error) {
+		if len(t) != 1 {
+			return None, ErrParaNum
+		}
+		x, err := p.Exec(t[0])
+		if err != nil {
+			return None, err
+		}
+		b, err := Encode(x)
+		if err != nil {
+			return None, err
+		}
+		return Token{Kind: String, Text: string(b)}, nil
+	})
+
+	//implementation of the system function "decode" used to convert a binary encoding in a string back to the value
+	Add("decode", func(t []Token, p *Lisp) (Token, error) {
+		if len(t) != 1 {
+			return None, ErrParaNum
+		}
+		x, err := p.Exec(t[0])
+		if err != nil {
+			return None, err
+		}
+		if x.Kind != String {
+			return None, ErrFitType
+		}
+		return Decode([]byte(x.Text.(string)))
+	})
+}
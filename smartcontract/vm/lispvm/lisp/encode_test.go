@@ -0,0 +1,161 @@
+package lisp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+var sample = Token{Kind: List, Text: []Token{
+	{Kind: Int, Text: int64(-2)},
+	{Kind: Float, Text: float64(1.5)},
+	{Kind: String, Text: "ab"},
+	{Kind: Fold, Text: []Token{{Kind: Label, Text: Name("x")}}},
+	{Kind: List, Text: []Token(nil)},
+	None,
+}}
+
+//sampleHex is the encoding of sample, it must never change
+const sampleHex = "01" + "0500000006" +
+	"01fffffffffffffffe" +
+	"023ff8000000000000" +
+	"03000000026162" +
+	"0400000001" + "060000000178" +
+	"0500000000" +
+	"00"
+
+func TestEncode(t *testing.T) {
+	b, err := Encode(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(b) != sampleHex {
+		t.Errorf("The encoding should be %s, but got %x\n", sampleHex, b)
+	}
+	for i := 0; i < 100; i++ {
+		c, err := Encode(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, c) {
+			t.Fatalf("The encoding is not deterministic, got %x and %x\n", b, c)
+		}
+	}
+
+	z1, _ := Encode(Token{Kind: Float, Text: float64(0)})
+	z2, _ := Encode(Token{Kind: Float, Text: math.Copysign(0, -1)})
+	if !bytes.Equal(z1, z2) {
+		t.Errorf("-0 and 0 should have the same encoding\n")
+	}
+
+	for _, x := range []Token{
+		{Kind: Back, Text: Gfac(nil)},
+		{Kind: Front, Text: &Lfac{}},
+		{Kind: Macro, Text: &Lfac{}},
+		{Kind: Operator, Text: byte('(')},
+		{Kind: Float, Text: math.NaN()},
+		{Kind: List, Text: []Token{{Kind: Back, Text: Gfac(nil)}}},
+	} {
+		_, err = Encode(x)
+		if err != ErrNoCodec {
+			t.Errorf("Error not checked, %v should be refused\n", x.Kind)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	b, _ := hex.DecodeString(sampleHex)
+	x, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.Eq(&sample) {
+		t.Errorf("The token should be %v, but got %v\n", sample, x)
+	}
+
+	_, err = Decode(append([]byte{2}, b[1:]...))
+	if err != ErrVersion {
+		t.Errorf("Error not checked, unknown version\n")
+	}
+
+	for _, s := range []string{
+		"",
+		"01",
+		sampleHex + "00",
+		sampleHex[:len(sampleHex)-2],
+		"0107",
+		"0101ff",
+		"0102fff8000000000001",
+		"01028000000000000000",
+		"0103000000056162",
+		"01060000000000",
+		"0105ffffffff",
+	} {
+		c, _ := hex.DecodeString(s)
+		_, err = Decode(c)
+		if err != ErrBadCode {
+			t.Errorf("Error not checked, %s should be refused, got %v\n", s, err)
+		}
+	}
+
+	deep := []byte{EncodingVersion}
+	for i := 0; i <= maxDepth+1; i++ {
+		deep = append(deep, tagList, 0, 0, 0, 1)
+	}
+	deep = append(deep, tagNull)
+	_, err = Decode(deep)
+	if err != ErrBadCode {
+		t.Errorf("Error not checked, nesting is too deep\n")
+	}
+}
+
+func TestEncodeDepth(t *testing.T) {
+	nest := func(n int) Token {
+		x := None
+		for i := 0; i < n; i++ {
+			x = Token{Kind: List, Text: []Token{x}}
+		}
+		return x
+	}
+
+	//the deepest nesting Decode accepts is encoded
+	b, err := Encode(nest(maxDepth))
+	if err != nil {
+		t.Fatalf("Nesting of %d lists should be encoded, %v\n", maxDepth, err)
+	}
+	if _, err = Decode(b); err != nil {
+		t.Errorf("Encoding of %d lists should be decoded, %v\n", maxDepth, err)
+	}
+
+	_, err = Encode(nest(maxDepth + 1))
+	if err != ErrTooDeep {
+		t.Errorf("Error not checked, nesting is too deep to be encoded\n")
+	}
+}
+
+func Test_encode(t *testing.T) {
+	l := NewLisp()
+	r, err := l.Eval([]byte(`(decode (encode (list 1 "a" '(b 2.5))))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Token{Kind: List, Text: []Token{
+		{Kind: Int, Text: int64(1)},
+		{Kind: String, Text: "a"},
+		{Kind: List, Text: []Token{{Kind: Label, Text: Name("b")}, {Kind: Float, Text: float64(2.5)}}},
+	}}
+	if !r.Eq(&want) {
+		t.Errorf("The result should be %v, but got %v\n", want, r)
+	}
+
+	_, err = l.Eval([]byte(`(encode (lambda () 1))`))
+	if err != ErrNoCodec {
+		t.Errorf("Error not checked, a function can't be encoded\n")
+	}
+
+	_, err = l.Eval([]byte(`(decode 1)`))
+	if err != ErrFitType {
+		t.Errorf("Error not checked, decode parameter should be a string\n")
+	}
+}
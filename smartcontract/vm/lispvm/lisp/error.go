@@ -19,12 +19,169 @@ var (
 	ErrRefused = errors.New("can't remove a back function")
 	ErrDivZero = errors.New("cannot divide zero")
 	ErrModZero = errors.New("cannot mod zero")
+	ErrIsConst = errors.New("can't change a constant")
+	ErrNoCodec = errors.New("lisp type can't be encoded")
+	ErrBadCode = errors.New("invalid encoding of token")
+	ErrVersion = errors.New("unknown encoding version")
+	ErrTooDeep = errors.New("token is nested too deep to be encoded")
 )
 
+//The followings define the condition codes of the errors above
+//CodeError is also the code of a condition raised with a message only
+const (
+	CodeError   = "error"
+	CodeNotOver = "not-over"
+	CodeUnquote = "unquote"
+	CodeNotFind = "not-find"
+	CodeNotFunc = "not-func"
+	CodeParaNum = "para-num"
+	CodeFitType = "fit-type"
+	CodeNotName = "not-name"
+	CodeIsEmpty = "is-empty"
+	CodeNotConv = "not-conv"
+	CodeRefused = "refused"
+	CodeDivZero = "div-zero"
+	CodeModZero = "mod-zero"
+	CodeIsConst = "is-const"
+	CodeNoCodec = "no-codec"
+	CodeBadCode = "bad-code"
+	CodeVersion = "version"
+	CodeTooDeep = "too-deep"
+)
+
+var errorCodes = map[error]string{
+	ErrNotOver: CodeNotOver,
+	ErrUnquote: CodeUnquote,
+	ErrNotFind: CodeNotFind,
+	ErrNotFunc: CodeNotFunc,
+	ErrParaNum: CodeParaNum,
+	ErrFitType: CodeFitType,
+	ErrNotName: CodeNotName,
+	ErrIsEmpty: CodeIsEmpty,
+	ErrNotConv: CodeNotConv,
+	ErrRefused: CodeRefused,
+	ErrDivZero: CodeDivZero,
+	ErrModZero: CodeModZero,
+	ErrIsConst: CodeIsConst,
+	ErrNoCodec: CodeNoCodec,
+	ErrBadCode: CodeBadCode,
+	ErrVersion: CodeVersion,
+	ErrTooDeep: CodeTooDeep,
+}
+
+//Condition is an error carrying a code and the data attached by the raiser
+//handler-case matches a condition by its code
+type Condition struct {
+	Code string
+	Data Token
+}
+
+//NewCondition returns a condition with given code and data
+func NewCondition(code string, data Token) *Condition {
+	return &Condition{Code: code, Data: data}
+}
+
+//Error implements the error interface
+//a condition raised with a message only is described by the message itself
+func (c *Condition) Error() string {
+	if c.Data.Kind == Null {
+		return c.Code
+	}
+	if c.Code == CodeError {
+		return c.Data.String()
+	}
+	return fmt.Sprintf("%s: %v", c.Code, c.Data)
+}
+
+//Token returns the list (code data) which is bound to the variable of a handler-case clause
+func (c *Condition) Token() Token {
+	return Token{Kind: List, Text: []Token{{Kind: String, Text: c.Code}, c.Data}}
+}
+
+//ConditionOf converts an error to a condition
+//the errors defined in this package get their own codes, any other error gets CodeError
+func ConditionOf(err error) *Condition {
+	if c, ok := err.(*Condition); ok {
+		return c
+	}
+	if code, ok := errorCodes[err]; ok {
+		return NewCondition(code, Token{Kind: String, Text: err.Error()})
+	}
+	return NewCondition(CodeError, Token{Kind: String, Text: fmt.Sprint(err)})
+}
+
+//CodeOf returns the condition code of an error, empty string for nil
+func CodeOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	return ConditionOf(err).Code
+}
+
+//handlerCase implements the system function "handler-case"
+//(handler-case form (code (var) body...) ...)
+//code is a string matched against the condition code, or _ which matches any condition
+//var is optional and bound to the list (code data) of the caught condition
+//if no clause matches, the condition is passed on
+func handlerCase(t []Token, p *Lisp) (Token, error) {
+	if len(t) < 2 {
+		return None, ErrParaNum
+	}
+	clauses := make([][]Token, len(t)-1)
+	for i, c := range t[1:] {
+		if c.Kind != List {
+			return None, ErrFitType
+		}
+		clause := c.Text.([]Token)
+		if len(clause) < 3 || clause[1].Kind != List || len(clause[1].Text.([]Token)) > 1 {
+			return None, ErrParaNum
+		}
+		switch clause[0].Kind {
+		case String:
+		case Label:
+			if clause[0].Text.(Name) != "_" {
+				return None, ErrFitType
+			}
+		default:
+			return None, ErrFitType
+		}
+		for _, v := range clause[1].Text.([]Token) {
+			if v.Kind != Label {
+				return None, ErrNotName
+			}
+		}
+		clauses[i] = clause
+	}
+	ans, err := p.Exec(t[0])
+	if err == nil {
+		return ans, nil
+	}
+	cond := ConditionOf(err)
+	for _, clause := range clauses {
+		if clause[0].Kind == String && clause[0].Text.(string) != cond.Code {
+			continue
+		}
+		q := &Lisp{parent: p, env: map[Name]Token{}, scopeName: "handler-case"}
+		if vars := clause[1].Text.([]Token); len(vars) == 1 {
+			q.env[vars[0].Text.(Name)] = cond.Token()
+		}
+		for _, body := range clause[2:] {
+			ans, err = q.Exec(body)
+			if err != nil {
+				return None, err
+			}
+		}
+		return ans, nil
+	}
+	return None, err
+}
+
 func init() {
-	//implementation of the system function "raise" used to converts a string to an error info
+	//implementation of the system function "raise" used to raise a condition
+	//(raise message) raises a condition of code "error" carrying the message
+	//(raise code data) raises a condition of the given code carrying the data
 	Add("raise", func(t []Token, p *Lisp) (Token, error) {
-		if len(t) != 1 {
+		if len(t) != 1 && len(t) != 2 {
 			return None, ErrParaNum
 		}
 		ans, err := p.Exec(t[0])
@@ -34,9 +191,22 @@ func init() {
 		if ans.Kind != String {
 			return None, ErrFitType
 		}
-		return None, fmt.Errorf(ans.Text.(string))
+		if len(t) == 1 {
+			return None, NewCondition(CodeError, ans)
+		}
+		data, err := p.Exec(t[1])
+		if err != nil {
+			return None, err
+		}
+		return None, NewCondition(ans.Text.(string), data)
 	})
 
+	//implementation of the system function "handler-case" used to handle a condition according to its code
+	Add("handler-case", handlerCase)
+
+	//implementation of the system function "try", same as "handler-case"
+	Add("try", handlerCase)
+
 	//implementation of the system function "catch" used to catch the error of executed parameter and make
 	//the error returned as return value with string type
 	Add("catch", func(t []Token, p *Lisp) (Token, error) {
@@ -0,0 +1,106 @@
+package lisp
+
+import "testing"
+
+func Test_raise(t *testing.T) {
+	l := NewLisp()
+	_, err := l.Eval([]byte(`(raise "out of range")`))
+	if err == nil {
+		t.Fatal("Error not raised\n")
+	}
+	if err.Error() != "out of range" {
+		t.Errorf("The error should be out of range, but got %v\n", err)
+	}
+	if CodeOf(err) != CodeError {
+		t.Errorf("The code should be %s, but got %s\n", CodeError, CodeOf(err))
+	}
+
+	_, err = l.Eval([]byte(`(raise "sig-invalid" (list 1 2))`))
+	if err == nil {
+		t.Fatal("Error not raised\n")
+	}
+	c, ok := err.(*Condition)
+	if !ok {
+		t.Fatalf("The error should be a condition, but got %T\n", err)
+	}
+	if c.Code != "sig-invalid" {
+		t.Errorf("The code should be sig-invalid, but got %s\n", c.Code)
+	}
+	if !c.Data.Eq(&Token{Kind: List, Text: []Token{{Int, int64(1)}, {Int, int64(2)}}}) {
+		t.Errorf("The data should be (1 2), but got %v\n", c.Data)
+	}
+
+	_, err = l.Eval([]byte(`(raise 1 2)`))
+	if err != ErrFitType {
+		t.Errorf("Error not checked, code should be a string\n")
+	}
+
+	_, err = l.Eval([]byte(`(raise "a" 1 2)`))
+	if err != ErrParaNum {
+		t.Errorf("Error not checked, too many parameters\n")
+	}
+
+	if CodeOf(ErrDivZero) != CodeDivZero {
+		t.Errorf("The code should be %s, but got %s\n", CodeDivZero, CodeOf(ErrDivZero))
+	}
+	if CodeOf(nil) != "" {
+		t.Errorf("The code of nil should be empty\n")
+	}
+}
+
+func Test_handlerCase(t *testing.T) {
+	l := NewLisp()
+	r, err := l.Eval([]byte(`(handler-case (+ 1 2) ("error" (e) 0))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: Int, Text: int64(3)}) {
+		t.Errorf("The result should be 3, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(handler-case (raise "sig-invalid" 7)
+		("param-missing" (e) 1)
+		("sig-invalid" (e) (car (cdr e))))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: Int, Text: int64(7)}) {
+		t.Errorf("The result should be 7, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(try (/ 1 0) ("div-zero" () "div"))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "div"}) {
+		t.Errorf("The result should be div, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(handler-case (car 1) (_ (e) (car e)))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: CodeFitType}) {
+		t.Errorf("The result should be %s, but got %v\n", CodeFitType, r)
+	}
+
+	_, err = l.Eval([]byte(`(handler-case (raise "sig-invalid" 7) ("param-missing" (e) 1))`))
+	if CodeOf(err) != "sig-invalid" {
+		t.Errorf("Unmatched condition should be passed on, but got %v\n", err)
+	}
+
+	_, err = l.Eval([]byte(`(handler-case (+ 1 2))`))
+	if err != ErrParaNum {
+		t.Errorf("Error not checked, no clause\n")
+	}
+
+	_, err = l.Eval([]byte(`(handler-case (+ 1 2) (1 (e) 1))`))
+	if err != ErrFitType {
+		t.Errorf("Error not checked, clause code should be a string\n")
+	}
+
+	_, err = l.Eval([]byte(`(handler-case (+ 1 2) ("error" (1) 1))`))
+	if err != ErrNotName {
+		t.Errorf("Error not checked, clause variable should be a label\n")
+	}
+}
@@ -69,6 +69,9 @@ func init() {
 			scope := p
 			for {
 				if scope.parent == Global || scope.env[symbol.Text.(Name)] != None {
+					if scope.isConst(symbol.Text.(Name)) {
+						return None, ErrIsConst
+					}
 					scope.env[symbol.Text.(Name)] = ans
 					break
 				}
@@ -116,6 +119,9 @@ func init() {
 		scope := p
 		for {
 			if scope.parent == Global || scope.env[funcName] != None {
+				if scope.isConst(funcName) {
+					return None, ErrIsConst
+				}
 				scope.env[funcName] = ans
 				break
 			}
@@ -233,6 +239,9 @@ func init() {
 		scope := p
 		for {
 			if scope.parent == Global || scope.env[funcName] != None {
+				if scope.isConst(funcName) {
+					return None, ErrIsConst
+				}
 				scope.env[funcName] = ans
 				break
 			}
@@ -9,8 +9,16 @@ import (
 //input is the raw data of go-lisp code
 //output is result of lexical analysis with an array of token
 func Scan(s []byte) (list []Token, err error) {
+	list, _, err = ScanOffset(s)
+	return
+}
+
+//ScanOffset does the lexical analysis as Scan
+//besides the array of token, it also outputs the offset of each token in the raw data
+func ScanOffset(s []byte) (list []Token, offset []int, err error) {
 	scanner := pattern.NewScanner(s, true)
 	list = make([]Token, 0, 100)
+	offset = make([]int, 0, 100)
 	for {
 		a, b, c := scanner.Scan()
 		if c != nil {
@@ -29,7 +37,10 @@ func Scan(s []byte) (list []Token, err error) {
 			list = append(list, Token{Kind: String, Text: a})
 		case 6:
 			list = append(list, Token{Kind: Label, Text: a})
+		default:
+			continue
 		}
+		offset = append(offset, scanner.Pos())
 	}
 	if !scanner.Over() {
 		err = ErrNotOver
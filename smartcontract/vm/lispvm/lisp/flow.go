@@ -177,6 +177,9 @@ func init() {
 			return None, ErrFitType
 		}
 		n := t[0].Text.(Name)
+		if p.isConst(n) {
+			return None, ErrIsConst
+		}
 		var rv Token
 		for _, m := range iter.Text.([]Token) {
 			p.env[n] = m
@@ -29,6 +29,9 @@ func init() {
 				return None, ErrParaNum
 			}
 
+			if p.isConst(a.Text.(Name)) {
+				return None, ErrIsConst
+			}
 			ans, err = p.Exec(t[1])
 			if err == nil {
 				p.env[a.Text.(Name)] = ans
@@ -46,6 +49,9 @@ func init() {
 				}
 				x[i] = c.Text.(Name)
 			}
+			if p.isConst(x[0]) {
+				return None, ErrIsConst
+			}
 			ans = Token{Kind: Front, Text: &Lfac{Para: x[1:], Text: t[1:], Make: p, FuncName: x[0]}}
 			p.env[x[0]] = ans
 			return ans, nil
@@ -79,6 +85,9 @@ func init() {
 		for v := p; p != Global; p = p.parent {
 			_, ok := p.env[n]
 			if ok {
+				if p.isConst(n) {
+					return None, ErrIsConst
+				}
 				if a.Kind == Label {
 					ans, err = p.Exec(b)
 					if err == nil {
@@ -118,6 +127,9 @@ func init() {
 		for ; p != Global; p = p.parent {
 			_, ok := p.env[n]
 			if ok {
+				if p.isConst(n) {
+					return None, ErrIsConst
+				}
 				delete(p.env, n)
 				return None, nil
 			}
@@ -157,11 +169,41 @@ func init() {
 	})
 
 	//implementation of the system function "clear" used to clear all the user defined functions and variables
+	//constants are kept
 	Add("clear", func(t []Token, p *Lisp) (ans Token, err error) {
 		if len(t) != 0 {
 			return None, ErrParaNum
 		}
-		p.env = map[Name]Token{}
+		env := map[Name]Token{}
+		for n := range p.consts {
+			env[n] = p.env[n]
+		}
+		p.env = env
 		return None, nil
 	})
+
+	//implementation of the system function "defconst" used to define a constant in current scope
+	//a constant can't be changed by define, update, setq, remove or clear
+	Add("defconst", func(t []Token, p *Lisp) (ans Token, err error) {
+		if len(t) != 2 {
+			return None, ErrParaNum
+		}
+		if t[0].Kind != Label {
+			return None, ErrNotName
+		}
+		n := t[0].Text.(Name)
+		if p.isConst(n) {
+			return None, ErrIsConst
+		}
+		ans, err = p.Exec(t[1])
+		if err != nil {
+			return None, err
+		}
+		p.Const(n, ans)
+		if p.defconsts == nil {
+			p.defconsts = map[Name]bool{}
+		}
+		p.defconsts[n] = true
+		return ans, nil
+	})
 }
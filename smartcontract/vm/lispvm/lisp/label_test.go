@@ -0,0 +1,76 @@
+package lisp
+
+import "testing"
+
+func Test_defconst(t *testing.T) {
+	l := NewLisp()
+	r, err := l.Eval([]byte(`(defconst issuer "key") issuer`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "key"}) {
+		t.Errorf("The result should be key, but got %v\n", r)
+	}
+
+	for _, code := range []string{
+		`(update issuer "other")`,
+		`(setq issuer "other")`,
+		`(remove issuer)`,
+		`(define issuer "other")`,
+		`(defconst issuer "other")`,
+		`(defun issuer () "other")`,
+		`(define (issuer) "other")`,
+		`(for issuer (list 1 2) 0)`,
+	} {
+		_, err = l.Eval([]byte(code))
+		if err != ErrIsConst {
+			t.Errorf("Error not checked, %s should be refused, but got %v\n", code, err)
+		}
+	}
+
+	r, err = l.Eval([]byte(`(define a 1) (clear) issuer`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "key"}) {
+		t.Errorf("The constant should be kept by clear, but got %v\n", r)
+	}
+	_, err = l.Eval([]byte(`a`))
+	if err != ErrNotFind {
+		t.Errorf("The variable should be removed by clear\n")
+	}
+
+	r, err = l.Eval([]byte(`((lambda () (define issuer 2) issuer))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: Int, Text: int64(2)}) {
+		t.Errorf("The constant should be shadowed in inner scope, but got %v\n", r)
+	}
+
+	_, err = l.Eval([]byte(`(defconst "issuer" 1)`))
+	if err != ErrNotName {
+		t.Errorf("Error not checked, constant name should be a label\n")
+	}
+
+	_, err = l.Eval([]byte(`(defconst b)`))
+	if err != ErrParaNum {
+		t.Errorf("Error not checked, too less parameter\n")
+	}
+}
+
+func TestLisp_Const(t *testing.T) {
+	l := NewLisp()
+	l.Const("owner", Token{Kind: String, Text: "addr"})
+	r, err := l.Eval([]byte(`owner`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "addr"}) {
+		t.Errorf("The result should be addr, but got %v\n", r)
+	}
+	_, err = l.Eval([]byte(`(setq owner "me")`))
+	if err != ErrIsConst {
+		t.Errorf("Error not checked, host constant should be refused\n")
+	}
+}
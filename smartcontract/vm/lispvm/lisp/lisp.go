@@ -16,7 +16,17 @@ type Lisp struct {
 	parent      *Lisp
 	scopeName   Name
 	env         map[Name]Token
+	consts      map[Name]bool
+	defconsts   map[Name]bool
 	returnValue Token
+	meter       *Meter
+}
+
+//Meter counts the steps charged by builtins during a program run
+//a zero Max means no limit
+type Meter struct {
+	Steps uint32
+	Max   uint32
 }
 
 //NewLisp returns a Lisp instance for a new running program
@@ -27,6 +37,55 @@ func NewLisp() *Lisp {
 	return x
 }
 
+//Const binds a value to a name as a constant in the scope
+//a constant can't be changed by define, update, setq, remove or clear
+//the host uses it to expose injected values to the running program
+func (l *Lisp) Const(n Name, t Token) {
+	if l.consts == nil {
+		l.consts = map[Name]bool{}
+	}
+	l.env[n] = t
+	l.consts[n] = true
+}
+
+//Unbind removes the binding of a name in the scope, whether it is a constant or not
+//the host uses it to drop the values it injected before injecting new ones
+func (l *Lisp) Unbind(n Name) {
+	delete(l.env, n)
+	delete(l.consts, n)
+	delete(l.defconsts, n)
+}
+
+//UnbindDefconsts removes the constants bound by defconst in the scope, the constants bound by Const are kept
+//the host uses it before running a program again, so the program can define its constants again
+func (l *Lisp) UnbindDefconsts() {
+	for n := range l.defconsts {
+		l.Unbind(n)
+	}
+	l.defconsts = nil
+}
+
+//SetMeter attaches a meter to the scope, it is done by the host on the program scope before a run
+func (l *Lisp) SetMeter(m *Meter) {
+	l.meter = m
+}
+
+//Meter returns the meter of the nearest enclosing scope, or nil if no scope has one
+//builtins use it to charge the program they are called from
+func (l *Lisp) Meter() *Meter {
+	for ; l != nil; l = l.parent {
+		if l.meter != nil {
+			return l.meter
+		}
+	}
+	return nil
+}
+
+//isConst tells whether a name is bound as a constant in the scope
+func (l *Lisp) isConst(n Name) bool {
+	return l.consts[n]
+}
+
 //Add adds the system function implementation to the global Lisp instance
 func Add(s string, f func([]Token, *Lisp) (Token, error)) {
 	Global.env[Name(s)] = Token{Back, Gfac(f)}
@@ -0,0 +1,132 @@
+package lisp
+
+//CodeNoMatch is the code of the condition raised when no arm of match matches the value
+const CodeNoMatch = "no-match"
+
+//matchPattern tries to match a value against a pattern and records the variable bindings
+//a pattern can be:
+//	a literal integer, float or string, which matches an equal value
+//	a fold list, which matches an equal list
+//	_, which matches anything
+//	a label, which matches anything and binds the value to it
+//	a list of patterns, which matches a list of the same length element by element
+//	a list of patterns ending with . and a label, which binds the rest elements to the label
+func matchPattern(pat, x Token, env map[Name]Token) (bool, error) {
+	switch pat.Kind {
+	case Int, Float, String:
+		return pat.Eq(&x), nil
+	case Fold:
+		lst := Token{Kind: List, Text: pat.Text.([]Token)}
+		return lst.Eq(&x), nil
+	case Label:
+		n := pat.Text.(Name)
+		if n == "." {
+			return false, ErrFitType
+		}
+		if n != "_" {
+			env[n] = x
+		}
+		return true, nil
+	case List:
+		pats := pat.Text.([]Token)
+		rest := -1
+		for i, c := range pats {
+			if c.Kind == Label && c.Text.(Name) == "." {
+				if i != len(pats)-2 || pats[i+1].Kind != Label {
+					return false, ErrFitType
+				}
+				rest = i
+			}
+		}
+		if x.Kind != List {
+			return false, nil
+		}
+		xs := x.Text.([]Token)
+		if rest < 0 {
+			if len(xs) != len(pats) {
+				return false, nil
+			}
+		} else {
+			if len(xs) < rest {
+				return false, nil
+			}
+			pats = pats[:rest]
+		}
+		for i, c := range pats {
+			ok, err := matchPattern(c, xs[i], env)
+			if err != nil || !ok {
+				return ok, err
+			}
+		}
+		if rest >= 0 {
+			tail := pat.Text.([]Token)[rest+1].Text.(Name)
+			if tail != "_" {
+				env[tail] = Token{Kind: List, Text: xs[rest:]}
+			}
+		}
+		return true, nil
+	}
+	return false, ErrFitType
+}
+
+//isGuard tells whether a token is a guard of match arm like (when condition)
+func isGuard(t Token) bool {
+	if t.Kind != List {
+		return false
+	}
+	ls := t.Text.([]Token)
+	return len(ls) == 2 && ls[0].Kind == Label && ls[0].Text.(Name) == "when"
+}
+
+func init() {
+	//implementation of the system function "match" used to branch on the shape of a value
+	//(match expr (pattern body...) (pattern (when guard) body...) ...)
+	//the arms are tried in order, the first arm whose pattern matches and whose guard is true is executed
+	//in a new scope holding the variables bound by the pattern
+	//if no arm matches, a condition of code "no-match" carrying the value is raised
+	Add("match", func(t []Token, p *Lisp) (ans Token, err error) {
+		if len(t) < 2 {
+			return None, ErrParaNum
+		}
+		for _, arm := range t[1:] {
+			if arm.Kind != List || len(arm.Text.([]Token)) < 2 {
+				return None, ErrFitType
+			}
+		}
+		x, err := p.Exec(t[0])
+		if err != nil {
+			return None, err
+		}
+		for _, arm := range t[1:] {
+			ls := arm.Text.([]Token)
+			q := &Lisp{parent: p, env: map[Name]Token{}, scopeName: "match"}
+			ok, err := matchPattern(ls[0], x, q.env)
+			if err != nil {
+				return None, err
+			}
+			if !ok {
+				continue
+			}
+			body := ls[1:]
+			if isGuard(body[0]) {
+				g, err := q.Exec(body[0].Text.([]Token)[1])
+				if err != nil {
+					return None, err
+				}
+				if !g.Bool() {
+					continue
+				}
+				body = body[1:]
+			}
+			ans = None
+			for _, b := range body {
+				ans, err = q.Exec(b)
+				if err != nil {
+					return None, err
+				}
+			}
+			return ans, nil
+		}
+		return None, NewCondition(CodeNoMatch, x)
+	})
+}
@@ -0,0 +1,84 @@
+package lisp
+
+import "testing"
+
+func Test_match(t *testing.T) {
+	l := NewLisp()
+	r, err := l.Eval([]byte(`(match 3 (1 "one") (3 "three") (_ "other"))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "three"}) {
+		t.Errorf("The result should be three, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(match "claim" ("refund" 1) ("claim" 2))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: Int, Text: int64(2)}) {
+		t.Errorf("The result should be 2, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(match (list "refund" 5 6 7)
+		(("claim" amount) amount)
+		(("refund" amount . rest) (+ amount (length rest))))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: Int, Text: int64(7)}) {
+		t.Errorf("The result should be 7, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(match (list "pay" 50)
+		(("pay" n) (when (> n 100)) "large")
+		(("pay" n) (when (> n 10)) "medium")
+		(("pay" _) "small"))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "medium"}) {
+		t.Errorf("The result should be medium, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(match (list 1 2) ('(1 2) "fold") (_ "other"))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "fold"}) {
+		t.Errorf("The result should be fold, but got %v\n", r)
+	}
+
+	r, err = l.Eval([]byte(`(match (list) ((x . _) x) (() "empty"))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Eq(&Token{Kind: String, Text: "empty"}) {
+		t.Errorf("The result should be empty, but got %v\n", r)
+	}
+
+	_, err = l.Eval([]byte(`(match 4 (1 "one") ("4" "four"))`))
+	if CodeOf(err) != CodeNoMatch {
+		t.Errorf("Error not checked, no arm matches, got %v\n", err)
+	}
+
+	_, err = l.Eval([]byte(`x`))
+	if err != ErrNotFind {
+		t.Errorf("Pattern variables should not leak out of match\n")
+	}
+
+	_, err = l.Eval([]byte(`(match 4 ((a . b c) 1))`))
+	if err != ErrFitType {
+		t.Errorf("Error not checked, rest should be the last pattern\n")
+	}
+
+	_, err = l.Eval([]byte(`(match 4)`))
+	if err != ErrParaNum {
+		t.Errorf("Error not checked, no arm\n")
+	}
+
+	_, err = l.Eval([]byte(`(match 4 1)`))
+	if err != ErrFitType {
+		t.Errorf("Error not checked, arm should be a list\n")
+	}
+}
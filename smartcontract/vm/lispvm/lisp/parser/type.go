@@ -14,10 +14,14 @@ type Pattern struct {
 //ptn is the lexical rule
 //tkn is the raw code to be analyze
 //skp decides whether to skip space
+//off is the offset of the remaining raw data
+//pos is the offset of the last analyzed lexical unit
 type Scanner struct {
 	ptn *Pattern
 	tkn []byte
 	skp bool
+	off int
+	pos int
 }
 
 //Add adds a lexical rule to a Scanner
@@ -45,6 +49,7 @@ func (s *Scanner) Skip() {
 		i++
 	}
 	s.tkn = s.tkn[i:]
+	s.off += i
 }
 
 //Scan try to analyze ONE lexical unit on raw data and prune the analyzed raw data
@@ -59,6 +64,7 @@ func (s *Scanner) Scan() (interface{}, int, error) {
 		l := len(t)
 		if len(s.tkn) >= l && t == string(s.tkn[:l]) {
 			s.tkn = s.tkn[l:]
+			s.pos, s.off = s.off, s.off+l
 			return t, -(i + 1), nil
 		}
 	}
@@ -66,12 +72,18 @@ func (s *Scanner) Scan() (interface{}, int, error) {
 		a, l := f(s.tkn)
 		if l > 0 {
 			s.tkn = s.tkn[l:]
+			s.pos, s.off = s.off, s.off+l
 			return a, +(i + 1), nil
 		}
 	}
 	return nil, 0, fmt.Errorf("unrecognised")
 }
 
+//Pos returns the offset of the last analyzed lexical unit in the raw data
+func (s *Scanner) Pos() int {
+	return s.pos
+}
+
 //Over tells whether there is data which is not analyzed yet
 func (s *Scanner) Over() bool {
 	return len(s.tkn) == 0
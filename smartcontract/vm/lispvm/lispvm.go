@@ -15,6 +15,9 @@
 package lispvm
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/SHDMT/gravity/infrastructure/log"
 	"github.com/SHDMT/gravity/platform/consensus/structure"
 	"github.com/SHDMT/gravity/platform/smartcontract/vm"
@@ -24,6 +27,32 @@ import (
 const (
 	//LispVMVersion is current VM version
 	LispVMVersion = 1
+
+	//ContractDefParamPrefix is the name prefix of contract definition parameters bound as constants
+	//e.g. the parameter "owner" is bound to the name def.owner
+	ContractDefParamPrefix = "def."
+)
+
+//The followings define the condition codes raised by LispVM builtins
+const (
+	//CodeSignatureInvalid is raised when a signature doesn't match the public key and content
+	CodeSignatureInvalid = "signature-invalid"
+	//CodePublicKeyInvalid is raised when a public key can't be parsed
+	CodePublicKeyInvalid = "public-key-invalid"
+	//CodeUnknownScheme is raised when the header of a public key is not a registered signature scheme
+	CodeUnknownScheme = "unknown-scheme"
+	//CodeParamMissing is raised when a requested parameter doesn't exist
+	CodeParamMissing = "param-missing"
+	//CodeVMPanic is the code of a contract execution recovered from a panic
+	CodeVMPanic = "vm-panic"
+	//CodeStepLimit is raised when the steps charged by builtins exceed MaxStep of the config
+	CodeStepLimit = "step-limit"
+	//CodeProofInvalid is raised when a merkle proof doesn't lead to the root
+	CodeProofInvalid = "proof-invalid"
+	//CodeOracleUnknown is raised when an oracle isn't in the registry
+	CodeOracleUnknown = "oracle-unknown"
+	//CodeOracleStale is raised when an oracle attestation is too old or from a future MCI
+	CodeOracleStale = "oracle-stale"
 )
 
 //LispVM is Lisp virtual machine
@@ -33,8 +62,12 @@ type LispVM struct {
 	context     vm.Context
 	curContract structure.Contract
 	vm          *lisp.Lisp
+	defNames    []lisp.Name
+	preverified []byte
 }
 
+var _ vm.Runner = (*LispVM)(nil)
+
 //ID returns unique identification of LispVM
 func (lispvm *LispVM) ID() uint32 {
 	return 0
@@ -61,27 +94,94 @@ func (lispvm *LispVM) Context() vm.Context {
 }
 
 //Exec returns contract execute result
-func (lispvm *LispVM) Exec(contract *structure.Contract) (ret bool) {
+func (lispvm *LispVM) Exec(contract *structure.Contract) bool {
+	return lispvm.Run(contract).Success
+}
+
+//Run executes the contract and returns the result with the condition code the contract failed with
+func (lispvm *LispVM) Run(contract *structure.Contract) (ret *vm.Result) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Errorf("Lisp vm error, recovered from %v", r)
-			ret = false
+			ret = &vm.Result{Success: false, Code: CodeVMPanic, Err: fmt.Errorf("%v", r)}
 		}
 	}()
 
+	lispvm.resetMeter()
+	lispvm.vm.UnbindDefconsts()
 	result, err := lispvm.vm.Eval(contract.Code)
 	if err != nil {
 		log.Error("execute the contract failed:", err)
-		return false
+		return &vm.Result{Success: false, Code: lisp.CodeOf(err), Err: err}
 	}
 
-	return result.Bool()
+	return &vm.Result{Success: result.Bool()}
+}
+
+//resetMeter attaches a new step meter to the program scope with MaxStep of the config
+func (lispvm *LispVM) resetMeter() {
+	lispvm.vm.SetMeter(&lisp.Meter{Max: lispvm.config.MaxStep})
+}
+
+//charge adds the steps of a builtin whose cost depends on its arguments to the program p belongs to
+//builtins are shared by all LispVM instances, so the meter is found from the scope instead of the receiver
+func charge(p *lisp.Lisp, n int) error {
+	m := p.Meter()
+	if m == nil {
+		return nil
+	}
+	m.Steps += uint32(n)
+	if m.Max > 0 && m.Steps > m.Max {
+		return lisp.NewCondition(CodeStepLimit, lisp.Token{Kind: lisp.Int, Text: int64(m.Steps)})
+	}
+	return nil
 }
 
 //SetEnv setup LispVM environment
+//the def.* constants of the previous context are replaced and the constants defined by defconst are dropped,
+//the rest of the program scope is kept
 func (lispvm *LispVM) SetEnv(context vm.Context, config vm.Config) {
 	lispvm.context = context
 	lispvm.config = config
+	lispvm.resetMeter()
+	lispvm.vm.UnbindDefconsts()
+	for _, name := range lispvm.defNames {
+		lispvm.vm.Unbind(name)
+	}
+	lispvm.bindContext()
+	lispvm.preverify()
+}
+
+//preverify fills the shared signature cache with the author signatures of the unit of the context
+//the contracts of a unit are run after several SetEnv, the unit is preverified at the first one
+func (lispvm *LispVM) preverify() {
+	unit := &lispvm.context.TxUnit
+	if len(unit.Authors) == 0 {
+		return
+	}
+	h := unit.GetHashToSign()
+	if bytes.Equal(h, lispvm.preverified) {
+		return
+	}
+	lispvm.preverified = h
+	PreverifyUnit(unit, 0)
+}
+
+//bindContext binds the values injected by the host as constants of the program scope
+func (lispvm *LispVM) bindContext() {
+	lispvm.defNames = lispvm.defNames[:0]
+	def := lispvm.context.ContractDef
+	if def == nil {
+		return
+	}
+	for i, key := range def.ParamsKey {
+		if i >= len(def.ParamsValue) {
+			break
+		}
+		name := lisp.Name(ContractDefParamPrefix + key)
+		lispvm.vm.Const(name, lisp.Token{Kind: lisp.String, Text: string(def.ParamsValue[i])})
+		lispvm.defNames = append(lispvm.defNames, name)
+	}
 }
 
 //NewLispVM creates a new LispVM object
@@ -90,10 +190,23 @@ func NewLispVM(context vm.Context, config vm.Config) *LispVM {
 	lispvm.context = context
 	lispvm.config = config
 	lispvm.vm = lisp.NewLisp()
+	lispvm.resetMeter()
+	lispvm.bindContext()
+	lispvm.preverify()
 
 	lisp.Add("verify", lispvm.verify)
 	lisp.Add("hash", lispvm.hash)
+	for name, sum := range digests {
+		lisp.Add(name, lispvm.digest(sum))
+	}
 	lisp.Add("verifyMultiSign", lispvm.verifyMultiSign)
+	lisp.Add("verifyMultiSignIndexed", lispvm.verifyMultiSignIndexed)
+	lisp.Add("pkToAddress", lispvm.pkToAddress)
+	lisp.Add("verifyWOTS", lispvm.verifyWOTS)
+	lisp.Add("verifyLamport", lispvm.verifyLamport)
+	lisp.Add("verifyXMSS", lispvm.verifyXMSS)
+	lisp.Add("verifyMerkleProof", lispvm.verifyMerkleProof)
+	lisp.Add("verifyOracle", lispvm.verifyOracle)
 
 	lisp.Add("sigCount", lispvm.sigCount)
 	lisp.Add("getPK", lispvm.getPK)
@@ -19,6 +19,7 @@ import (
 
 	"github.com/SHDMT/gravity/platform/consensus/structure"
 	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
 )
 
 var code1 = `
@@ -56,3 +57,101 @@ func TestLispVMPanic(t *testing.T) {
 		t.Errorf("Code should detected divide zero err.")
 	}
 }
+
+func TestLispVMRunCode(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+
+	contract := structure.Contract{
+		Version:    1,
+		Name:       "raise program",
+		ScriptCode: vm.LispScriptCode,
+		Code:       []byte(`(raise "param-missing" "owner")`),
+	}
+	ret := lvm.Run(&contract)
+	if ret.Success {
+		t.Errorf("Code should fail with a raised condition.")
+	}
+	if ret.Code != CodeParamMissing {
+		t.Errorf("The code should be %s, but got %s", CodeParamMissing, ret.Code)
+	}
+
+	contract.Code = []byte(`(handler-case (raise "param-missing" "owner") ("param-missing" (e) 1))`)
+	ret = lvm.Run(&contract)
+	if !ret.Success || ret.Code != "" || ret.Err != nil {
+		t.Errorf("Handled condition should not fail the contract, got %v", ret)
+	}
+
+	contract.Code = []byte(code2)
+	ret = lvm.Run(&contract)
+	if ret.Success || ret.Code != lisp.CodeDivZero {
+		t.Errorf("The code should be %s, but got %s", lisp.CodeDivZero, ret.Code)
+	}
+}
+
+func TestLispVMContractDefConst(t *testing.T) {
+	def := structure.NewContractDef()
+	def.ParamsKey = []string{"owner"}
+	def.ParamsValue = [][]byte{[]byte("issuer key")}
+
+	lvm := NewLispVM(vm.Context{ContractDef: def}, vm.Config{Mode: vm.VMModeContract})
+	contract := structure.Contract{
+		Version:    1,
+		Name:       "const program",
+		ScriptCode: vm.LispScriptCode,
+		Code:       []byte(`(eq def.owner "issuer key")`),
+	}
+	if !lvm.Exec(&contract) {
+		t.Errorf("Contract definition parameter should be bound to def.owner.")
+	}
+
+	contract.Code = []byte(`(setq def.owner "other key")`)
+	ret := lvm.Run(&contract)
+	if ret.Success || ret.Code != lisp.CodeIsConst {
+		t.Errorf("The code should be %s, but got %s", lisp.CodeIsConst, ret.Code)
+	}
+
+	contract.Code = []byte(`(define kept 1)`)
+	lvm.Run(&contract)
+
+	lvm.SetEnv(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	contract.Code = []byte(`def.owner`)
+	ret = lvm.Run(&contract)
+	if ret.Success || ret.Code != lisp.CodeNotFind {
+		t.Errorf("Constants of previous context should be dropped, but got %v", ret)
+	}
+
+	//the rest of the program scope is kept
+	contract.Code = []byte(`(eq kept 1)`)
+	if !lvm.Exec(&contract) {
+		t.Errorf("Program definitions should be kept by SetEnv.")
+	}
+}
+
+func TestLispVMDefconstRerun(t *testing.T) {
+	def := structure.NewContractDef()
+	def.ParamsKey = []string{"owner"}
+	def.ParamsValue = [][]byte{[]byte("issuer key")}
+
+	lvm := NewLispVM(vm.Context{ContractDef: def}, vm.Config{Mode: vm.VMModeContract})
+	contract := structure.Contract{
+		Version:    1,
+		Name:       "defconst program",
+		ScriptCode: vm.LispScriptCode,
+		Code:       []byte(`(defconst issuer "k") (eq issuer "k")`),
+	}
+	//a reused VM runs the same contract again, also after a new context is set
+	for i := 0; i < 2; i++ {
+		if ret := lvm.Run(&contract); !ret.Success {
+			t.Errorf("Run %d of the contract failed, %v", i, ret.Err)
+		}
+	}
+	lvm.SetEnv(vm.Context{ContractDef: def}, vm.Config{Mode: vm.VMModeContract})
+	if ret := lvm.Run(&contract); !ret.Success {
+		t.Errorf("Run after SetEnv failed, %v", ret.Err)
+	}
+
+	contract.Code = []byte(`(eq def.owner "issuer key")`)
+	if !lvm.Exec(&contract) {
+		t.Errorf("Contract definition constants should be kept across runs.")
+	}
+}
@@ -0,0 +1,146 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//SortedTreePrefix is the prefix of hash algorithms of sorted-pair trees
+//e.g. "sorted-keccak256" hashes the smaller node first at every level and ignores the index
+const SortedTreePrefix = "sorted-"
+
+var (
+	//ErrUnknownHashAlg is returned when the hash algorithm of a merkle tree isn't a digest builtin
+	ErrUnknownHashAlg = errors.New("unknown hash algorithm")
+	//ErrProofFormat is returned when the nodes of a proof don't match the digest size
+	ErrProofFormat = errors.New("invalid merkle proof format")
+)
+
+//merkleHasher returns the hash function of the tree and whether pairs are sorted
+func merkleHasher(alg string) (func([]byte) []byte, bool, error) {
+	sorted := strings.HasPrefix(alg, SortedTreePrefix)
+	sum, ok := digests[strings.TrimPrefix(alg, SortedTreePrefix)]
+	if !ok {
+		return nil, false, ErrUnknownHashAlg
+	}
+	return sum, sorted, nil
+}
+
+//MerkleProofRoot folds the proof of the leaf at index and returns the root
+//in a positioned tree, like the bitcoin sha256d tree, bit i of index tells if the node of level i is the right child
+func MerkleProofRoot(leaf []byte, proof [][]byte, index int64, alg string) ([]byte, error) {
+	sum, sorted, err := merkleHasher(alg)
+	if err != nil {
+		return nil, err
+	}
+	size := len(sum(nil))
+	if len(leaf) != size {
+		return nil, ErrProofFormat
+	}
+	if !sorted && (index < 0 || len(proof) < 63 && index >= int64(1)<<uint(len(proof))) {
+		return nil, ErrProofFormat
+	}
+	node := leaf
+	buf := make([]byte, 2*size)
+	for _, sibling := range proof {
+		if len(sibling) != size {
+			return nil, ErrProofFormat
+		}
+		right := index&1 == 1
+		if sorted {
+			right = string(sibling) < string(node)
+		}
+		if right {
+			copy(buf, sibling)
+			copy(buf[size:], node)
+		} else {
+			copy(buf, node)
+			copy(buf[size:], sibling)
+		}
+		node = sum(buf)
+		index >>= 1
+	}
+	return node, nil
+}
+
+//proofNodes returns the nodes of a proof given as a byte-string or a list of byte-strings
+func proofNodes(x lisp.Token, size int) ([][]byte, error) {
+	switch x.Kind {
+	case lisp.String:
+		b := []byte(x.Text.(string))
+		if len(b)%size != 0 {
+			return nil, ErrProofFormat
+		}
+		nodes := make([][]byte, 0, len(b)/size)
+		for i := 0; i < len(b); i += size {
+			nodes = append(nodes, b[i:i+size])
+		}
+		return nodes, nil
+	case lisp.List:
+		nodes := make([][]byte, 0)
+		for _, n := range x.Text.([]lisp.Token) {
+			if n.Kind != lisp.String {
+				return nil, lisp.ErrFitType
+			}
+			nodes = append(nodes, []byte(n.Text.(string)))
+		}
+		return nodes, nil
+	}
+	return nil, lisp.ErrFitType
+}
+
+//verifyMerkleProof checks the leaf is included in the tree of the root
+//(verifyMerkleProof root leaf proof index hashAlg)
+//every level of the proof costs one step
+func (lispvm *LispVM) verifyMerkleProof(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 5 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	args := make([]lisp.Token, len(t))
+	for i, v := range t {
+		x, err := p.Exec(v)
+		if err != nil {
+			return lisp.None, err
+		}
+		args[i] = x
+	}
+	root, leaf, proof, index, alg := args[0], args[1], args[2], args[3], args[4]
+	if root.Kind != lisp.String || leaf.Kind != lisp.String || index.Kind != lisp.Int || alg.Kind != lisp.String {
+		return lisp.None, lisp.ErrFitType
+	}
+	sum, _, err := merkleHasher(alg.Text.(string))
+	if err != nil {
+		return lisp.None, err
+	}
+	nodes, err := proofNodes(proof, len(sum(nil)))
+	if err != nil {
+		return lisp.None, err
+	}
+	if err := charge(p, 1+len(nodes)); err != nil {
+		return lisp.None, err
+	}
+	node, err := MerkleProofRoot([]byte(leaf.Text.(string)), nodes, index.Text.(int64), alg.Text.(string))
+	if err != nil {
+		return lisp.None, err
+	}
+	if string(node) != root.Text.(string) {
+		return lisp.False, lisp.NewCondition(CodeProofInvalid, leaf)
+	}
+	return lisp.True, nil
+}
@@ -0,0 +1,140 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/SHDMT/gravity/platform/consensus/structure"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//internalHash decodes a hash displayed in bitcoin byte order
+func internalHash(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+func TestVerifyMerkleProof(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	//transactions of bitcoin block 100000
+	txs := [][]byte{
+		internalHash("8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		internalHash("fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		internalHash("6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		internalHash("e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	}
+	root := internalHash("f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766")
+	n01 := digests["sha256d"](append(append([]byte{}, txs[0]...), txs[1]...))
+	alg := lisp.Token{Kind: lisp.String, Text: "sha256d"}
+
+	//proof as byte-string
+	proof := str(append(append([]byte{}, txs[3]...), n01...))
+	index := lisp.Token{Kind: lisp.Int, Text: int64(2)}
+	ret, err := lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), proof, index, alg}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("sha256d merkle proof failed,err= %v", err)
+	}
+	//proof as list
+	list := lisp.Token{Kind: lisp.Fold, Text: []lisp.Token{str(txs[3]), str(n01)}}
+	ret, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), list, index, alg}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("sha256d merkle proof list failed,err= %v", err)
+	}
+	//wrong index
+	wrong := lisp.Token{Kind: lisp.Int, Text: int64(3)}
+	_, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), proof, wrong, alg}, lvm.vm)
+	if lisp.CodeOf(err) != CodeProofInvalid {
+		t.Errorf("merkle proof of wrong index should fail,err= %v", err)
+	}
+	//index out of tree
+	wrong = lisp.Token{Kind: lisp.Int, Text: int64(4)}
+	_, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), proof, wrong, alg}, lvm.vm)
+	if err != ErrProofFormat {
+		t.Errorf("merkle proof of index out of tree should fail,err= %v", err)
+	}
+	//truncated proof
+	_, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), str(txs[3][1:]), index, alg}, lvm.vm)
+	if err != ErrProofFormat {
+		t.Errorf("truncated merkle proof should fail,err= %v", err)
+	}
+	//unknown hash algorithm
+	unknown := lisp.Token{Kind: lisp.String, Text: "md5"}
+	_, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(txs[2]), proof, index, unknown}, lvm.vm)
+	if err != ErrUnknownHashAlg {
+		t.Errorf("unknown hash algorithm should fail,err= %v", err)
+	}
+}
+
+func TestVerifySortedMerkleProof(t *testing.T) {
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	sum := digests["keccak256"]
+	pair := func(a, b []byte) []byte {
+		if string(b) < string(a) {
+			a, b = b, a
+		}
+		return sum(append(append([]byte{}, a...), b...))
+	}
+	leaves := [][]byte{sum([]byte("a")), sum([]byte("b")), sum([]byte("c")), sum([]byte("d"))}
+	n01 := pair(leaves[0], leaves[1])
+	root := pair(n01, pair(leaves[2], leaves[3]))
+	alg := lisp.Token{Kind: lisp.String, Text: SortedTreePrefix + "keccak256"}
+	proof := str(append(append([]byte{}, leaves[3]...), n01...))
+
+	//the index is ignored by sorted trees
+	for _, i := range []int64{0, 2, 7} {
+		index := lisp.Token{Kind: lisp.Int, Text: i}
+		ret, err := lvm.verifyMerkleProof([]lisp.Token{str(root), str(leaves[2]), proof, index, alg}, lvm.vm)
+		if err != nil || !ret.Bool() {
+			t.Errorf("sorted merkle proof failed,index= %d,err= %v", i, err)
+		}
+	}
+	index := lisp.Token{Kind: lisp.Int, Text: int64(0)}
+	_, err := lvm.verifyMerkleProof([]lisp.Token{str(root), str(leaves[1]), proof, index, alg}, lvm.vm)
+	if lisp.CodeOf(err) != CodeProofInvalid {
+		t.Errorf("sorted merkle proof of other leaf should fail,err= %v", err)
+	}
+
+	//the proof of 2 levels costs 3 steps
+	lvm = NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract, MaxStep: 2})
+	_, err = lvm.verifyMerkleProof([]lisp.Token{str(root), str(leaves[2]), proof, index, alg}, lvm.vm)
+	if lisp.CodeOf(err) != CodeStepLimit {
+		t.Errorf("merkle proof should exceed the step limit,err= %v", err)
+	}
+
+	//builtins are bound to the newest VM, but steps are charged to the program calling them
+	limited := lvm
+	unlimited := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	contract := &structure.Contract{Code: []byte(`(verifyMerkleProof root leaf proof 0 alg)`)}
+	for _, x := range []*LispVM{limited, unlimited} {
+		x.vm.Const("root", str(root))
+		x.vm.Const("leaf", str(leaves[2]))
+		x.vm.Const("proof", proof)
+		x.vm.Const("alg", alg)
+	}
+	ret := limited.Run(contract)
+	if ret.Code != CodeStepLimit {
+		t.Errorf("program of the limited VM should exceed the step limit, got %v", ret)
+	}
+	ret = unlimited.Run(contract)
+	if !ret.Success {
+		t.Errorf("program of the unlimited VM should pass, got %v", ret)
+	}
+}
@@ -0,0 +1,90 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"encoding/binary"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//DefaultMaxOracleAge is the max MCIs an oracle attestation is accepted after when the config doesn't set one
+const DefaultMaxOracleAge = 100
+
+//OracleAttestationHash returns the hash an oracle signs to attest the value of key at mci
+//oracleID | length of key(4) | key | length of value(4) | value | mci(8)
+func OracleAttestationHash(oracleID, key, value []byte, mci uint64) hash.HashType {
+	buf := make([]byte, 0, len(oracleID)+len(key)+len(value)+16)
+	buf = append(buf, oracleID...)
+	var l [8]byte
+	binary.BigEndian.PutUint32(l[:4], uint32(len(key)))
+	buf = append(buf, l[:4]...)
+	buf = append(buf, key...)
+	binary.BigEndian.PutUint32(l[:4], uint32(len(value)))
+	buf = append(buf, l[:4]...)
+	buf = append(buf, value...)
+	binary.BigEndian.PutUint64(l[:], mci)
+	buf = append(buf, l[:]...)
+	return hash.Sum256(buf)
+}
+
+//verifyOracle checks an attestation signed by a registered oracle
+//(verifyOracle oracleID key value mci sig)
+//the attestation is refused if its mci is after the current MCI or older than MaxOracleAge
+func (lispvm *LispVM) verifyOracle(t []lisp.Token, p *lisp.Lisp) (lisp.Token, error) {
+	if len(t) != 5 {
+		return lisp.None, lisp.ErrParaNum
+	}
+	args := make([]lisp.Token, len(t))
+	for i, v := range t {
+		x, err := p.Exec(v)
+		if err != nil {
+			return lisp.None, err
+		}
+		if i == 3 && x.Kind != lisp.Int || i != 3 && x.Kind != lisp.String {
+			return lisp.None, lisp.ErrFitType
+		}
+		args[i] = x
+	}
+	id := []byte(args[0].Text.(string))
+	var pk []byte
+	if lispvm.context.FetchOracle != nil {
+		pk = lispvm.context.FetchOracle(id)
+	}
+	if pk == nil {
+		return lisp.None, lisp.NewCondition(CodeOracleUnknown, args[0])
+	}
+
+	mci := args[3].Text.(int64)
+	maxAge := lispvm.config.MaxOracleAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxOracleAge
+	}
+	if mci < 0 || uint64(mci) > lispvm.context.MCI || lispvm.context.MCI-uint64(mci) > maxAge {
+		return lisp.None, lisp.NewCondition(CodeOracleStale, args[3])
+	}
+
+	pkToken := lisp.Token{Kind: lisp.String, Text: string(pk)}
+	pubKey, err := parsePublicKeyToken(pkToken)
+	if err != nil {
+		return lisp.None, err
+	}
+	h := OracleAttestationHash(id, []byte(args[1].Text.(string)), []byte(args[2].Text.(string)), uint64(mci))
+	if !sigCache.Verify(pk, pubKey, h, []byte(args[4].Text.(string))) {
+		return lisp.False, lisp.NewCondition(CodeSignatureInvalid, args[0])
+	}
+	return lisp.True, nil
+}
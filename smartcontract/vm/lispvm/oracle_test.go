@@ -0,0 +1,79 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+func TestVerifyOracle(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate key failed")
+	}
+	pk := append([]byte{SchemeEd25519}, pub...)
+	oracleID := hash.Sum256(pk)
+	context := vm.Context{
+		MCI: 1000,
+		FetchOracle: func(id hash.HashType) []byte {
+			if id.IsEqual(oracleID) {
+				return pk
+			}
+			return nil
+		},
+	}
+	lvm := NewLispVM(context, vm.Config{Mode: vm.VMModeContract, MaxOracleAge: 10})
+
+	attest := func(id []byte, key, value string, mci int64) []lisp.Token {
+		sig := ed25519.Sign(priv, OracleAttestationHash(oracleID, []byte(key), []byte(value), uint64(mci)))
+		return []lisp.Token{str(id), str([]byte(key)), str([]byte(value)), {Kind: lisp.Int, Text: mci}, str(sig)}
+	}
+
+	ret, err := lvm.verifyOracle(attest(oracleID, "BTC/USD", "64000", 995), lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("verifyOracle failed,err= %v", err)
+	}
+	//value changed after signing
+	tks := attest(oracleID, "BTC/USD", "64000", 995)
+	tks[2] = str([]byte("1"))
+	_, err = lvm.verifyOracle(tks, lvm.vm)
+	if lisp.CodeOf(err) != CodeSignatureInvalid {
+		t.Errorf("verifyOracle of changed value should fail,err= %v", err)
+	}
+	//stale and future mci
+	for _, mci := range []int64{989, 1001, -1} {
+		_, err = lvm.verifyOracle(attest(oracleID, "BTC/USD", "64000", mci), lvm.vm)
+		if lisp.CodeOf(err) != CodeOracleStale {
+			t.Errorf("verifyOracle of mci %d should be stale,err= %v", mci, err)
+		}
+	}
+	//unregistered oracle
+	_, err = lvm.verifyOracle(attest(hash.Sum256([]byte("unknown")), "BTC/USD", "64000", 995), lvm.vm)
+	if lisp.CodeOf(err) != CodeOracleUnknown {
+		t.Errorf("verifyOracle of unknown oracle should fail,err= %v", err)
+	}
+	//no registry
+	lvm = NewLispVM(vm.Context{MCI: 1000}, vm.Config{Mode: vm.VMModeContract})
+	_, err = lvm.verifyOracle(attest(oracleID, "BTC/USD", "64000", 995), lvm.vm)
+	if lisp.CodeOf(err) != CodeOracleUnknown {
+		t.Errorf("verifyOracle without registry should fail,err= %v", err)
+	}
+}
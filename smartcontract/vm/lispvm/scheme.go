@@ -0,0 +1,124 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/SHDMT/crypto/bliss"
+	"github.com/SHDMT/crypto/secp256k1"
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+//The followings define the header bytes of public keys used by contracts
+//a public key passed to verify is the header byte followed by the marshaled key
+const (
+	//SchemeSecp256k1 is the header of secp256k1 public keys
+	SchemeSecp256k1 byte = 0
+	//SchemeBliss is the header of BLISS public keys
+	SchemeBliss byte = 1
+	//SchemeEd25519 is the header of RFC 8032 ed25519 public keys
+	SchemeEd25519 byte = 2
+	//SchemeSchnorr is the header of BIP-340 schnorr x-only public keys
+	SchemeSchnorr byte = 3
+)
+
+var (
+	//ErrUnknownScheme is returned when the header of a public key is not registered
+	ErrUnknownScheme = errors.New("unknown signature scheme")
+	//ErrSchemeRegistered is returned when registering a header which is already registered
+	ErrSchemeRegistered = errors.New("signature scheme is already registered")
+	//ErrInvalidPublicKey is returned when a public key can't be unmarshaled
+	ErrInvalidPublicKey = errors.New("unmarshal public key failed")
+)
+
+var (
+	schemesLock sync.RWMutex
+	schemes     = map[byte]func() asymmetric.PublicKey{
+		SchemeSecp256k1: func() asymmetric.PublicKey { return new(secp256k1.PublicKey) },
+		SchemeBliss:     func() asymmetric.PublicKey { return new(bliss.PublicKey) },
+		SchemeEd25519:   func() asymmetric.PublicKey { return new(ed25519PublicKey) },
+		SchemeSchnorr:   func() asymmetric.PublicKey { return new(schnorrPublicKey) },
+	}
+)
+
+//RegisterScheme registers the constructor of public keys for a header byte
+//it allows downstream users to support extra signature schemes in verify and verifyMultiSign
+func RegisterScheme(header byte, newKey func() asymmetric.PublicKey) error {
+	schemesLock.Lock()
+	defer schemesLock.Unlock()
+	if _, ok := schemes[header]; ok {
+		return ErrSchemeRegistered
+	}
+	schemes[header] = newKey
+	return nil
+}
+
+//ParsePublicKey parses a public key made of the header byte and the marshaled key
+func ParsePublicKey(pk []byte) (asymmetric.PublicKey, error) {
+	if len(pk) == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	schemesLock.RLock()
+	newKey, ok := schemes[pk[0]]
+	schemesLock.RUnlock()
+	if !ok {
+		return nil, ErrUnknownScheme
+	}
+	pubKey := newKey()
+	if err := pubKey.UnmarshalP(pk[1:]); err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return pubKey, nil
+}
+
+//AddressOf returns the author address of a public key made of the header byte and the marshaled key
+func AddressOf(pk []byte) (hash.HashType, error) {
+	if _, err := ParsePublicKey(pk); err != nil {
+		return nil, err
+	}
+	return addressOf(pk), nil
+}
+
+//addressOf hashes a parsed public key to its address
+//secp256k1 and BLISS addresses are the hash of the marshaled key, the same as the addresses of unit authors
+//the other schemes hash the header byte too, so keys of different schemes with the same body differ
+func addressOf(pk []byte) hash.HashType {
+	if pk[0] == SchemeSecp256k1 || pk[0] == SchemeBliss {
+		return hash.Sum256(pk[1:])
+	}
+	return hash.Sum256(pk)
+}
+
+//parsePublicKeyToken parses a public key given to a builtin
+func parsePublicKeyToken(x lisp.Token) (asymmetric.PublicKey, error) {
+	if x.Kind != lisp.String {
+		return nil, errors.New("public key is not string")
+	}
+	if len(x.Text.(string)) == 0 {
+		return nil, errors.New("public key is empty")
+	}
+	pubKey, err := ParsePublicKey([]byte(x.Text.(string)))
+	if err == ErrUnknownScheme {
+		return nil, lisp.NewCondition(CodeUnknownScheme, x)
+	}
+	if err != nil {
+		return nil, lisp.NewCondition(CodePublicKeyInvalid, x)
+	}
+	return pubKey, nil
+}
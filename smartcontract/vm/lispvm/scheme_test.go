@@ -0,0 +1,105 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric/ec/secp256k1"
+	"github.com/SHDMT/gravity/infrastructure/crypto/hash"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	priK, err := secp256k1.NewCipherSuite().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate key failed")
+	}
+	body, _ := priK.Public().MarshalP()
+
+	_, err = ParsePublicKey(append([]byte{SchemeSecp256k1}, body...))
+	if err != nil {
+		t.Errorf("parse secp256k1 public key failed, err= %v", err)
+	}
+
+	_, err = ParsePublicKey(append([]byte{7}, body...))
+	if err != ErrUnknownScheme {
+		t.Errorf("header 7 should be an unknown scheme, err= %v", err)
+	}
+
+	_, err = ParsePublicKey(nil)
+	if err != ErrInvalidPublicKey {
+		t.Errorf("empty public key should be invalid, err= %v", err)
+	}
+
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	PK := lisp.Token{Kind: lisp.String, Text: string(append([]byte{7}, body...))}
+	chash := lisp.Token{Kind: lisp.String, Text: string(hash.Sum256([]byte("testcontent")))}
+	sig := lisp.Token{Kind: lisp.String, Text: "xx"}
+	_, err = lvm.verify([]lisp.Token{PK, chash, sig}, lvm.vm)
+	if lisp.CodeOf(err) != CodeUnknownScheme {
+		t.Errorf("verify should raise %s, err= %v", CodeUnknownScheme, err)
+	}
+	_, err = lvm.verifyMultiSign([]lisp.Token{
+		{Kind: lisp.Fold, Text: []lisp.Token{PK}}, chash,
+		{Kind: lisp.Fold, Text: []lisp.Token{sig}}, {Kind: lisp.Int, Text: int64(1)}}, lvm.vm)
+	if lisp.CodeOf(err) != CodeUnknownScheme {
+		t.Errorf("verifyMultiSign should raise %s, err= %v", CodeUnknownScheme, err)
+	}
+}
+
+func TestRegisterScheme(t *testing.T) {
+	const header = 0x7f
+	err := RegisterScheme(header, func() asymmetric.PublicKey { return new(secp256k1.PublicKey) })
+	if err != nil {
+		t.Fatalf("register scheme failed, err= %v", err)
+	}
+	defer func() {
+		schemesLock.Lock()
+		delete(schemes, header)
+		schemesLock.Unlock()
+	}()
+	err = RegisterScheme(header, func() asymmetric.PublicKey { return new(secp256k1.PublicKey) })
+	if err != ErrSchemeRegistered {
+		t.Errorf("register scheme twice should fail, err= %v", err)
+	}
+	err = RegisterScheme(SchemeBliss, func() asymmetric.PublicKey { return new(secp256k1.PublicKey) })
+	if err != ErrSchemeRegistered {
+		t.Errorf("builtin scheme should not be replaced, err= %v", err)
+	}
+
+	priK, err := secp256k1.NewCipherSuite().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate key failed")
+	}
+	body, _ := priK.Public().MarshalP()
+	hashedContent := hash.Sum256([]byte("testcontent"))
+	sign, err := priK.Sign(hashedContent)
+	if err != nil {
+		t.Fatal("sign failed")
+	}
+
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+	PK := lisp.Token{Kind: lisp.String, Text: string(append([]byte{header}, body...))}
+	chash := lisp.Token{Kind: lisp.String, Text: string(hashedContent)}
+	sig := lisp.Token{Kind: lisp.String, Text: string(sign)}
+	ret, err := lvm.verify([]lisp.Token{PK, chash, sig}, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("verify with registered scheme failed, err= %v", err)
+	}
+}
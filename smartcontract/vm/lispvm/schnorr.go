@@ -0,0 +1,179 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/SHDMT/crypto/secp256k1"
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+)
+
+//ErrSchnorrPublicKey is returned when a BIP-340 public key is not a valid x coordinate
+var ErrSchnorrPublicKey = errors.New("invalid schnorr public key")
+
+//sqrtExp is (p+1)/4, p = 3 mod 4 so c^sqrtExp is a square root of c
+var sqrtExp = new(big.Int).Rsh(new(big.Int).Add(secp256k1.S256().Params().P, big.NewInt(1)), 2)
+
+//liftX returns the y coordinate of the point with the x coordinate and an even y
+func liftX(x *big.Int) (*big.Int, bool) {
+	params := secp256k1.S256().Params()
+	if x.Cmp(params.P) >= 0 {
+		return nil, false
+	}
+	c := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	c.Add(c, params.B).Mod(c, params.P)
+	y := new(big.Int).Exp(c, sqrtExp, params.P)
+	if new(big.Int).Exp(y, big.NewInt(2), params.P).Cmp(c) != 0 {
+		return nil, false
+	}
+	if y.Bit(0) == 1 {
+		y.Sub(params.P, y)
+	}
+	return y, true
+}
+
+//taggedHash is the tagged hash of BIP-340
+func taggedHash(tag string, msg ...[]byte) []byte {
+	t := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(t[:])
+	h.Write(t[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+	return h.Sum(nil)
+}
+
+//schnorrPublicKey is a BIP-340 x-only public key
+type schnorrPublicKey struct {
+	data []byte
+	x, y *big.Int
+}
+
+//Verify checks the BIP-340 signature of hash
+func (pk *schnorrPublicKey) Verify(hash []byte, signature []byte) bool {
+	if pk.x == nil || len(signature) != 64 {
+		return false
+	}
+	curve := secp256k1.S256()
+	params := curve.Params()
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if r.Cmp(params.P) >= 0 || s.Cmp(params.N) >= 0 {
+		return false
+	}
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", signature[:32], pk.data, hash))
+	e.Sub(params.N, e.Mod(e, params.N)).Mod(e, params.N)
+	//R = s*G - e*P
+	sx, sy := curve.ScalarBaseMult(s.Bytes())
+	ex, ey := curve.ScalarMult(pk.x, pk.y, e.Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+	if (rx.Sign() == 0 && ry.Sign() == 0) || ry.Bit(0) == 1 {
+		return false
+	}
+	return rx.Cmp(r) == 0
+}
+
+//verifySchnorrBatch is the BIP-340 batch verification
+//it checks (a0*s0 + ... + an*sn)*G = a0*R0 + ... + an*Rn + (a0*e0)*P0 + ... + (an*en)*Pn with random ai
+//if the sum doesn't hold the signatures are verified one by one to find the invalid ones
+func verifySchnorrBatch(keys []asymmetric.PublicKey, hashes [][]byte, sigs [][]byte) []bool {
+	curve := secp256k1.S256()
+	params := curve.Params()
+	results := make([]bool, len(keys))
+	batch := make([]int, 0, len(keys))
+	sum := new(big.Int)
+	var x, y *big.Int
+	for i, key := range keys {
+		pk, ok := key.(*schnorrPublicKey)
+		if !ok || pk.x == nil || len(sigs[i]) != 64 {
+			results[i] = key.Verify(hashes[i], sigs[i])
+			continue
+		}
+		r := new(big.Int).SetBytes(sigs[i][:32])
+		s := new(big.Int).SetBytes(sigs[i][32:])
+		if s.Cmp(params.N) >= 0 {
+			continue
+		}
+		ry, ok := liftX(r)
+		if !ok {
+			continue
+		}
+		//the first coefficient is 1 as BIP-340 suggests
+		a := big.NewInt(1)
+		if len(batch) > 0 {
+			var err error
+			a, err = rand.Int(rand.Reader, new(big.Int).Sub(params.N, big.NewInt(1)))
+			if err != nil {
+				return verifyEach(keys, hashes, sigs)
+			}
+			a.Add(a, big.NewInt(1))
+		}
+		e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", sigs[i][:32], pk.data, hashes[i]))
+		e.Mul(e, a).Mod(e, params.N)
+		sum.Add(sum, new(big.Int).Mul(a, s)).Mod(sum, params.N)
+
+		ax, ay := curve.ScalarMult(r, ry, a.Bytes())
+		ex, ey := curve.ScalarMult(pk.x, pk.y, e.Bytes())
+		ax, ay = curve.Add(ax, ay, ex, ey)
+		if x == nil {
+			x, y = ax, ay
+		} else {
+			x, y = curve.Add(x, y, ax, ay)
+		}
+		batch = append(batch, i)
+	}
+	if len(batch) == 0 {
+		return results
+	}
+	sx, sy := curve.ScalarBaseMult(sum.Bytes())
+	if sx.Cmp(x) == 0 && sy.Cmp(y) == 0 {
+		for _, i := range batch {
+			results[i] = true
+		}
+		return results
+	}
+	for _, i := range batch {
+		results[i] = keys[i].Verify(hashes[i], sigs[i])
+	}
+	return results
+}
+
+//MarshalP returns the 32 bytes x-only public key
+func (pk *schnorrPublicKey) MarshalP() ([]byte, error) {
+	if pk.x == nil {
+		return nil, ErrSchnorrPublicKey
+	}
+	return append([]byte(nil), pk.data...), nil
+}
+
+//UnmarshalP parses a 32 bytes x-only public key
+func (pk *schnorrPublicKey) UnmarshalP(data []byte) error {
+	if len(data) != 32 {
+		return ErrSchnorrPublicKey
+	}
+	x := new(big.Int).SetBytes(data)
+	y, ok := liftX(x)
+	if !ok {
+		return ErrSchnorrPublicKey
+	}
+	pk.data = append([]byte(nil), data...)
+	pk.x, pk.y = x, y
+	return nil
+}
@@ -0,0 +1,139 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/SHDMT/gravity/infrastructure/crypto/asymmetric"
+)
+
+//DefaultSigCacheSize is the max entries of the signature cache shared by all LispVM instances
+const DefaultSigCacheSize = 50000
+
+//SigCacheStats is the metrics of a signature cache
+type SigCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+//SigCache is a bounded concurrency-safe cache of successful signature verifications
+//failed verifications are never cached, so a later valid check of the same triple can't be refused by the cache
+//an entry is keyed by the digest of the public key with its scheme header, the content hash and the signature
+type SigCache struct {
+	hits    uint64
+	misses  uint64
+	lock    sync.RWMutex
+	size    int
+	entries map[[sha256.Size]byte]struct{}
+}
+
+//NewSigCache creates a signature cache holding at most size entries
+//a zero size disables the cache
+func NewSigCache(size int) *SigCache {
+	return &SigCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]struct{}),
+	}
+}
+
+var sigCache = NewSigCache(DefaultSigCacheSize)
+
+//DefaultSigCache returns the signature cache consulted by verify and verifyMultiSign
+func DefaultSigCache() *SigCache {
+	return sigCache
+}
+
+//sigCacheKey returns the digest of the triple, every part is length prefixed
+func sigCacheKey(pk, h, sig []byte) [sha256.Size]byte {
+	d := sha256.New()
+	var l [4]byte
+	for _, b := range [][]byte{pk, h, sig} {
+		binary.BigEndian.PutUint32(l[:], uint32(len(b)))
+		d.Write(l[:])
+		d.Write(b)
+	}
+	var key [sha256.Size]byte
+	copy(key[:], d.Sum(nil))
+	return key
+}
+
+//Verify returns true if the triple is cached, or verifies the signature and caches it if it is valid
+//pk is the public key with its scheme header and pubKey is the parsed key
+func (c *SigCache) Verify(pk []byte, pubKey asymmetric.PublicKey, h, sig []byte) bool {
+	if c.size <= 0 {
+		return pubKey.Verify(h, sig)
+	}
+	key := sigCacheKey(pk, h, sig)
+	if c.lookup(key) {
+		return true
+	}
+	if !pubKey.Verify(h, sig) {
+		return false
+	}
+	c.add(key)
+	return true
+}
+
+//lookup tells whether key is cached and counts the hit or miss
+func (c *SigCache) lookup(key [sha256.Size]byte) bool {
+	c.lock.RLock()
+	_, ok := c.entries[key]
+	c.lock.RUnlock()
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return ok
+}
+
+//add caches key as a successful verification
+func (c *SigCache) add(key [sha256.Size]byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	//evict an arbitrary entry when the cache is full
+	if len(c.entries) >= c.size {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = struct{}{}
+}
+
+//Stats returns the metrics of the cache
+func (c *SigCache) Stats() SigCacheStats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return SigCacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: len(c.entries),
+	}
+}
+
+//Clear drops all entries and resets the metrics
+func (c *SigCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[[sha256.Size]byte]struct{})
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+}
@@ -0,0 +1,124 @@
+// This file is part of the Dazzle Gravity library.
+//
+// The Dazzle Gravity library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Dazzle Gravity library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Dazzle Gravity library. If not, see <e <http://www.gnu.org/licenses/>./>.
+package lispvm
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/SHDMT/gravity/platform/smartcontract/vm"
+	"github.com/SHDMT/gravity/platform/smartcontract/vm/lispvm/lisp"
+)
+
+func TestSigCache(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("generate key failed")
+	}
+	pk := append([]byte{SchemeEd25519}, pub...)
+	pubKey, err := ParsePublicKey(pk)
+	if err != nil {
+		t.Fatal("parse key failed")
+	}
+	contents := [][]byte{[]byte("content0"), []byte("content1"), []byte("content2")}
+
+	c := NewSigCache(2)
+	for i := 0; i < 2; i++ {
+		if !c.Verify(pk, pubKey, contents[0], ed25519.Sign(priv, contents[0])) {
+			t.Errorf("verify failed")
+		}
+	}
+	//invalid results are not cached
+	for i := 0; i < 2; i++ {
+		if c.Verify(pk, pubKey, contents[1], ed25519.Sign(priv, contents[0])) {
+			t.Errorf("verify of wrong content should fail")
+		}
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Entries != 1 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+	//the cache is bounded
+	c.Verify(pk, pubKey, contents[1], ed25519.Sign(priv, contents[1]))
+	c.Verify(pk, pubKey, contents[2], ed25519.Sign(priv, contents[2]))
+	if stats = c.Stats(); stats.Entries != 2 || stats.Misses != 5 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+	c.Clear()
+	if stats = c.Stats(); stats != (SigCacheStats{}) {
+		t.Errorf("wrong stats after clear %+v", stats)
+	}
+	//a zero size cache doesn't cache
+	c = NewSigCache(0)
+	c.Verify(pk, pubKey, contents[0], ed25519.Sign(priv, contents[0]))
+	if stats = c.Stats(); stats != (SigCacheStats{}) {
+		t.Errorf("disabled cache should be empty %+v", stats)
+	}
+}
+
+func TestSigCacheConcurrent(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	pk := append([]byte{SchemeEd25519}, pub...)
+	pubKey, _ := ParsePublicKey(pk)
+	content := []byte("content")
+	sig := ed25519.Sign(priv, content)
+
+	c := NewSigCache(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !c.Verify(pk, pubKey, content, sig) {
+					t.Errorf("verify failed")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := c.Stats(); stats.Hits+stats.Misses != 800 || stats.Entries != 1 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+}
+
+func TestVerifyUsesSigCache(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	pk := append([]byte{SchemeEd25519}, pub...)
+	content := []byte("content")
+	sig := ed25519.Sign(priv, content)
+	lvm := NewLispVM(vm.Context{}, vm.Config{Mode: vm.VMModeContract})
+
+	DefaultSigCache().Clear()
+	for i := 0; i < 3; i++ {
+		_, err := lvm.verify([]lisp.Token{str(pk), str(content), str(sig)}, lvm.vm)
+		if err != nil {
+			t.Errorf("verify failed,err= %v", err)
+		}
+	}
+	multi := []lisp.Token{
+		{Kind: lisp.Fold, Text: []lisp.Token{str(pk)}}, str(content),
+		{Kind: lisp.Fold, Text: []lisp.Token{str(sig)}}, {Kind: lisp.Int, Text: int64(1)},
+	}
+	ret, err := lvm.verifyMultiSign(multi, lvm.vm)
+	if err != nil || !ret.Bool() {
+		t.Errorf("verifyMultiSign failed,err= %v", err)
+	}
+	if stats := DefaultSigCache().Stats(); stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+}
@@ -33,3 +33,20 @@ type VM interface {
 	Exec(contract structure.Contract) bool
 	SetEnv(context Context, config Config)
 }
+
+//Runner is implemented by a VM reporting the condition code a contract failed with
+//hosts check for it on the VM they hold, Exec only tells if the contract is satisfied
+type Runner interface {
+	//Run executes the contract and returns the result with the condition code the contract failed with
+	Run(contract *structure.Contract) *Result
+}
+
+//Result is the outcome of a contract execution seen by the host
+type Result struct {
+	//Success marks if the contract is satisfied
+	Success bool
+	//Code is the condition code the contract failed with, it is empty if no error is raised
+	Code string
+	//Err is the error the contract failed with
+	Err error
+}